@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"simpletools/internal/api/middlewares"
+	"simpletools/internal/bootstrap"
+	"simpletools/internal/configs"
+	"simpletools/internal/data"
+	"simpletools/internal/users"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cmdServe       = "serve"
+	cmdVersion     = "version"
+	cmdCheckConfig = "check-config"
+	cmdDumpConfig  = "dump-config"
+	cmdGenToken    = "gen-token"
+	cmdPasswd      = "passwd"
+	cmdStop        = "stop"
+	cmdStatus      = "status"
+	cmdReload      = "reload"
+
+	usageHeader = `Usage: simpletools [flags] [command] [flags]
+
+Commands:
+  serve          启动服务(默认)
+  version        输出构建信息
+  check-config   读取并校验配置后退出
+  dump-config    输出合并默认值、配置文件、环境变量和命令行参数后的配置，隐藏密钥
+  gen-token      使用配置中的jwt_key签发token，需要 --user
+  passwd         从标准输入读取密码，新建用户或修改密码，需要 --user；服务运行时请使用 /api/admin/user/save
+  stop           停止pid文件记录的进程
+  status         查看pid文件记录的进程是否运行
+  reload         通知运行中的进程重新加载配置
+
+Flags:
+`
+)
+
+// options 命令行参数，flag同时接受 -name 和 --name
+type options struct {
+	config   string
+	workdir  string
+	host     string
+	logLevel string
+	user     string
+	platform string
+	version  bool // 兼容旧的 -v
+}
+
+// parseArgs 子命令前后都可以写flag，例如 simpletools --config x.json serve --host :8080
+func parseArgs(args []string) (string, *options, error) {
+	opts := &options{}
+	fs := flag.NewFlagSet("simpletools", flag.ContinueOnError)
+	fs.StringVar(&opts.config, "config", useConfig, "配置文件，只写文件名时从工作目录的etc下读取")
+	fs.StringVar(&opts.workdir, "workdir", "", "工作目录，配置、日志和pid文件的相对路径基于该目录")
+	fs.StringVar(&opts.host, "host", "", "覆盖配置中的监听地址 IP:PORT")
+	fs.StringVar(&opts.logLevel, "log-level", "", "覆盖配置中的日志级别 trace/debug/info/warn/error 或数字")
+	fs.StringVar(&opts.user, "user", "", "gen-token签发或passwd修改的用户名")
+	fs.StringVar(&opts.platform, "platform", "", "gen-token签发的平台，为空时使用配置中的platform")
+	fs.BoolVar(&opts.version, "v", false, "输出构建信息，同version命令")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usageHeader)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	cmd := cmdServe
+	if fs.NArg() > 0 {
+		cmd = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return "", nil, err
+		}
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return "", nil, fmt.Errorf("unexpected argument:%s", fs.Arg(0))
+	}
+	if opts.version {
+		cmd = cmdVersion
+	}
+	switch cmd {
+	case cmdServe, cmdVersion, cmdCheckConfig, cmdDumpConfig, cmdGenToken, cmdPasswd, cmdStop, cmdStatus, cmdReload:
+	default:
+		fs.Usage()
+		return "", nil, fmt.Errorf("unknown command:%s", cmd)
+	}
+	if (cmd == cmdGenToken || cmd == cmdPasswd) && opts.user == "" {
+		return "", nil, fmt.Errorf("%s requires --user", cmd)
+	}
+	return cmd, opts, nil
+}
+
+// override 命令行参数覆盖配置文件中的值
+func (o *options) override() (func(*configs.ServerConfig), error) {
+	level := 0
+	if o.logLevel != "" {
+		if n, err := strconv.Atoi(o.logLevel); err == nil {
+			level = n
+		} else if lvl, err := zerolog.ParseLevel(o.logLevel); err == nil {
+			level = int(lvl)
+		} else {
+			return nil, fmt.Errorf("invalid log level:%s", o.logLevel)
+		}
+	}
+	return func(cfg *configs.ServerConfig) {
+		if o.host != "" {
+			cfg.Host = o.host
+		}
+		if o.logLevel != "" {
+			cfg.Logger.LogLevel = level
+		}
+	}, nil
+}
+
+// runCli 执行serve以外的命令并返回退出码，serve时done为false，由main继续启动服务
+func runCli(args []string) (opts *options, override func(*configs.ServerConfig), code int, done bool) {
+	cmd, opts, err := parseArgs(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, nil, 0, true
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, 2, true
+	}
+	if opts.workdir != "" {
+		if err = os.Chdir(opts.workdir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, 1, true
+		}
+	}
+	if override, err = opts.override(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, 2, true
+	}
+
+	switch cmd {
+	case cmdServe:
+		return opts, override, 0, false
+	case cmdVersion:
+		fmt.Println(bootstrap.BuildInfo())
+		return nil, nil, 0, true
+	case cmdStop, cmdStatus, cmdReload:
+		code, _ = bootstrap.Control(cmd, opts.config)
+		return nil, nil, code, true
+	}
+
+	cfg, err := data.ReadConfig(opts.config, override)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, 1, true
+	}
+	switch cmd {
+	case cmdCheckConfig:
+		fmt.Printf("config %s ok, host:%s debug:%v providers:%d\n", opts.config, cfg.Host, cfg.Debug, len(cfg.LLM.Providers))
+	case cmdDumpConfig:
+		bs, err := jsoniter.MarshalIndent(cfg.Masked(), "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, 1, true
+		}
+		fmt.Println(string(bs))
+	case cmdGenToken:
+		data.GConfig.Store(cfg) // 签发token只依赖jwt_key，不初始化其他模块
+		platform := opts.platform
+		if platform == "" {
+			platform = cfg.Platform
+		}
+		token, _, err := middlewares.GenerateToken(opts.user, platform, "", time.Now().Add(time.Duration(cfg.Auth.TokenTTL)*time.Second))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, 1, true
+		}
+		fmt.Println(token)
+	case cmdPasswd:
+		if err = passwd(cfg.Auth.UsersPath, opts.user, os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, 1, true
+		}
+		fmt.Printf("user %s saved to %s\n", opts.user, cfg.Auth.UsersPath)
+	}
+	return nil, nil, 0, true
+}
+
+// passwd 读取第一行作为密码，用户不存在时新建，已存在时只修改密码
+func passwd(usersPath, username string, in io.Reader) error {
+	if usersPath == "" {
+		return fmt.Errorf("auth.users_path is not configured")
+	}
+	store, err := users.NewFileStore(usersPath)
+	if err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	hash, err := users.HashPassword(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return err
+	}
+	user, err := store.Get(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		user = &users.User{Username: username}
+	}
+	user.PasswordHash = hash
+	return store.Save(user)
+}
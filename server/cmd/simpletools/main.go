@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"os"
 	ctx "simpletools/internal/api/context"
 	"simpletools/internal/api/handlers"
 	"simpletools/internal/api/middlewares"
@@ -9,10 +11,7 @@ import (
 	"simpletools/internal/data"
 )
 
-const (
-	useConfig = "server_simpletools_debug.json"
-	pidFile   = "simpletools.pid"
-)
+const useConfig = "server_simpletools_debug.json" // 默认配置，发布环境通过 --config 指定
 
 func wrapHandler(cb ctx.CustomHandlerFunc) gin.HandlerFunc {
 	return ctx.WrapHandler(cb)
@@ -21,17 +20,69 @@ func wrapHandler(cb ctx.CustomHandlerFunc) gin.HandlerFunc {
 func registerHandlers(r *gin.Engine) {
 	publicRoutes := r.Group("/api/", middlewares.Validate(false))
 	{
-		publicRoutes.POST("/aitranslate", wrapHandler(handlers.OnAITranslateHandler))
-		publicRoutes.POST("/ainamed", wrapHandler(handlers.OnAINamedHandler))
+		publicRoutes.POST("/auth/login", wrapHandler(handlers.OnAuthLoginHandler))
+		publicRoutes.POST("/auth/refresh", wrapHandler(handlers.OnAuthRefreshHandler))
+		publicRoutes.POST("/glossary/list", wrapHandler(handlers.OnGlossaryListHandler))
+		publicRoutes.POST("/glossary/get", wrapHandler(handlers.OnGlossaryGetHandler))
+	}
+
+	// 大模型相关接口，auth.require_login开启时必须登录
+	aiRoutes := r.Group("/api/", middlewares.Validate(false), middlewares.Login())
+	{
+		aiRoutes.POST("/aitranslate", wrapHandler(handlers.OnAITranslateHandler))
+		aiRoutes.POST("/aitranslate/batch", wrapHandler(handlers.OnAIBatchTranslateHandler))
+		aiRoutes.POST("/aitranslate/document", wrapHandler(handlers.OnAIDocumentHandler))
+		aiRoutes.POST("/ainamed", wrapHandler(handlers.OnAINamedHandler))
+		aiRoutes.GET("/jobs/:id", wrapHandler(handlers.OnJobGetHandler))
+		aiRoutes.POST("/jobs/:id/cancel", wrapHandler(handlers.OnJobCancelHandler))
+	}
+
+	authRoutes := r.Group("/api/auth/", middlewares.Validate(true))
+	{
+		authRoutes.POST("/logout", wrapHandler(handlers.OnAuthLogoutHandler))
+		authRoutes.POST("/me", wrapHandler(handlers.OnAuthMeHandler))
+		authRoutes.POST("/sessions", wrapHandler(handlers.OnAuthSessionListHandler))
+		authRoutes.POST("/sessions/kill", wrapHandler(handlers.OnAuthSessionKillHandler))
+	}
+
+	adminRoutes := r.Group("/api/admin/", middlewares.Validate(true), middlewares.Admin())
+	{
+		adminRoutes.POST("/usage", wrapHandler(handlers.OnAdminUsageHandler))
+		adminRoutes.POST("/sink", wrapHandler(handlers.OnAdminSinkHandler))
+		adminRoutes.POST("/nonce", wrapHandler(handlers.OnAdminNonceHandler))
+		adminRoutes.POST("/config", wrapHandler(handlers.OnAdminConfigHandler))
+		adminRoutes.POST("/remote", wrapHandler(handlers.OnAdminRemoteHandler))
+		adminRoutes.POST("/glossary/save", wrapHandler(handlers.OnGlossarySaveHandler))
+		adminRoutes.POST("/glossary/delete", wrapHandler(handlers.OnGlossaryDeleteHandler))
+		adminRoutes.POST("/user/list", wrapHandler(handlers.OnAdminUserListHandler))
+		adminRoutes.POST("/user/save", wrapHandler(handlers.OnAdminUserSaveHandler))
+		adminRoutes.POST("/user/delete", wrapHandler(handlers.OnAdminUserDeleteHandler))
+		adminRoutes.POST("/sessions", wrapHandler(handlers.OnAdminSessionListHandler))
+		adminRoutes.POST("/sessions/kill", wrapHandler(handlers.OnAdminSessionKillHandler))
 	}
 }
 
 func main() {
-	if err := data.InitGlobal(useConfig); err != nil {
+	opts, override, code, done := runCli(os.Args[1:])
+	if done {
+		os.Exit(code)
+	}
+	cfg, err := data.ReadConfig(opts.config, override)
+	if err != nil {
+		panic(err)
+	}
+	if err = bootstrap.AcquirePid(cfg); err != nil { // 在加载任何数据之前确认没有其他进程在运行
+		fmt.Fprintf(os.Stderr, "acquire pid file failed: %v\n", err)
+		os.Exit(1)
+	}
+	if err = data.InitGlobal(opts.config, override); err != nil {
 		panic(err)
 	}
 
 	r := gin.New()
+	if err = r.SetTrustedProxies(data.Config().TrustedProxies); err != nil { // 默认信任所有代理，X-Forwarded-For可以被伪造
+		panic(err)
+	}
 	r.Use(middlewares.Recover())
 	r.Use(middlewares.Exit())
 	r.Use(middlewares.Cors())
@@ -40,5 +91,5 @@ func main() {
 
 	registerHandlers(r)
 
-	bootstrap.Bootstrap(pidFile, r)
+	bootstrap.Bootstrap(r)
 }
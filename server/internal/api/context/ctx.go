@@ -1,6 +1,7 @@
 package ctx
 
 import (
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"simpletools/internal/data"
@@ -8,12 +9,19 @@ import (
 	"simpletools/internal/utils"
 )
 
+const (
+	StreamEventDelta = "delta" // 增量文本
+	StreamEventDone  = "done"  // 结束事件，携带用量和结束原因
+	StreamEventError = "error" // 流式过程中出现错误
+)
+
 type CustomHandlerFunc func(*CustomContext) *defs.CustomError
 
 type CustomContext struct {
 	Ctx *gin.Context
 
 	decodeParams utils.AnyJson
+	streaming    bool // 已切换为SSE响应
 }
 
 func WrapHandler(f CustomHandlerFunc) gin.HandlerFunc {
@@ -21,7 +29,11 @@ func WrapHandler(f CustomHandlerFunc) gin.HandlerFunc {
 		cc, _ := c.Get("customContext")
 		ctx := cc.(*CustomContext)
 		if cErr := f(ctx); cErr != nil {
-			ctx.Abort(cErr, nil)
+			if ctx.IsStreaming() { // 响应头已发出，只能通过事件通知客户端
+				_ = ctx.StreamEvent(StreamEventError, gin.H{"code": cErr.GetCode(), "msg": cErr.GetErr()})
+			} else {
+				ctx.Abort(cErr, nil)
+			}
 			data.GLog.Error().CErr(cErr).Msg("server report error")
 		}
 	}
@@ -35,6 +47,32 @@ func (cc *CustomContext) AnswerOK(data any) {
 	cc.Ctx.JSON(http.StatusOK, gin.H{"code": defs.ErrCodeOK, "msg": "ok", "data": data})
 }
 
+// StreamStart 切换为SSE响应，之后只能通过StreamEvent输出，不能再调用AnswerOK/Abort
+func (cc *CustomContext) StreamStart() {
+	cc.streaming = true
+	header := cc.Ctx.Writer.Header()
+	header.Set("Content-Type", sse.ContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 关闭nginx代理缓冲，保证分片实时到达
+	cc.Ctx.Status(http.StatusOK)
+	cc.Ctx.Writer.Flush()
+}
+
+// StreamEvent 写入一个SSE事件并立即刷新，客户端断开时返回错误以便中断上游
+func (cc *CustomContext) StreamEvent(event string, data any) error {
+	if err := cc.Ctx.Request.Context().Err(); err != nil {
+		return err
+	}
+	cc.Ctx.SSEvent(event, data)
+	cc.Ctx.Writer.Flush()
+	return nil
+}
+
+func (cc *CustomContext) IsStreaming() bool {
+	return cc.streaming
+}
+
 func (cc *CustomContext) tryInitParams() {
 	if cc.decodeParams != nil {
 		return
@@ -54,6 +92,17 @@ func (cc *CustomContext) GetInt64(key string) int64 {
 	return cc.decodeParams.GetInt64(key)
 }
 
+// Decode 把参数key解析到out中，参数不存在时out保持不变
+func (cc *CustomContext) Decode(key string, out any) error {
+	cc.tryInitParams()
+	return cc.decodeParams.Decode(key, out)
+}
+
+func (cc *CustomContext) GetBool(key string) bool {
+	cc.tryInitParams()
+	return cc.decodeParams.GetBool(key)
+}
+
 func (cc *CustomContext) Username() string {
 	return cc.Ctx.GetString("username")
 }
@@ -61,3 +110,26 @@ func (cc *CustomContext) Username() string {
 func (cc *CustomContext) Platform() string {
 	return cc.Ctx.GetString("platform")
 }
+
+// ClientKey 请求来源，签名校验通过时为客户端id，未签名时为ip；只有trusted_proxies中的代理转发的X-Forwarded-For才会被使用
+func ClientKey(c *gin.Context) string {
+	if client := c.GetString("client"); client != "" {
+		return client
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Client 见ClientKey，区分未登录的请求
+func (cc *CustomContext) Client() string {
+	return ClientKey(cc.Ctx)
+}
+
+// SessionId 登录会话id，gen-token签发的token为空
+func (cc *CustomContext) SessionId() string {
+	return cc.Ctx.GetString("sid")
+}
+
+// TokenId 本次请求使用的token的jti和过期时间，退出登录时吊销
+func (cc *CustomContext) TokenId() (string, int64) {
+	return cc.Ctx.GetString("jti"), cc.Ctx.GetInt64("exp")
+}
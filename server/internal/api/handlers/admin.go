@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	ctx "simpletools/internal/api/context"
+	"simpletools/internal/data"
+	"simpletools/internal/defs"
+	"simpletools/internal/sink"
+)
+
+type usageAnswer struct {
+	Day   string            `json:"day"`
+	Days  []string          `json:"days"` // 可查询的日期
+	Stats []*data.UsageStat `json:"stats"`
+}
+
+type sinkAnswer struct {
+	QueueLen int                `json:"queue_len"`
+	QueueCap int                `json:"queue_cap"`
+	Sources  []sink.SourceStats `json:"sources"`
+}
+
+// OnAdminSinkHandler 查看主循环事件队列的长度和各来源的计数
+func OnAdminSinkHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	ctx.AnswerOK(sinkAnswer{QueueLen: len(data.GSink.SinkQ), QueueCap: cap(data.GSink.SinkQ), Sources: data.GSink.Stats()})
+	return nil
+}
+
+// OnAdminNonceHandler 查看nonce存储的容量和各客户端被拒绝的重放请求数
+func OnAdminNonceHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	ctx.AnswerOK(data.GNonces.Stats())
+	return nil
+}
+
+// OnAdminUsageHandler 查看某天的大模型用量，参数 day(20060102，默认今天) username(可选)
+func OnAdminUsageHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	day := ctx.GetString("day")
+	username := ctx.GetString("username")
+	if day == "" {
+		day = data.UsageToday()
+	}
+
+	stats := data.GUsage.GetByDay(day, username)
+	ctx.AnswerOK(usageAnswer{Day: day, Days: data.GUsage.Days(), Stats: stats})
+	return nil
+}
+
+// OnAdminConfigHandler 查看当前生效的配置，密钥已隐藏
+func OnAdminConfigHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	ctx.AnswerOK(data.Config().Masked())
+	return nil
+}
+
+// OnAdminRemoteHandler 查看远端数据的来源和最近一次请求的结果，refresh=true时立即在后台请求一次
+func OnAdminRemoteHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	if data.GRemote == nil {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("remote_addr is not configured"))
+	}
+	if ctx.GetBool("refresh") {
+		data.GRemote.Refresh()
+	}
+	ctx.AnswerOK(data.GRemote.Status())
+	return nil
+}
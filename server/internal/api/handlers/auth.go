@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	ctx "simpletools/internal/api/context"
+	"simpletools/internal/api/middlewares"
+	"simpletools/internal/data"
+	"simpletools/internal/defs"
+	"simpletools/internal/users"
+	"simpletools/internal/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+type loginAnswer struct {
+	Token            string `json:"token"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"` // 只能使用一次，刷新后返回新的刷新token
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+	SessionId        string `json:"session_id"`
+	Username         string `json:"username"`
+	Platform         string `json:"platform"`
+}
+
+type meAnswer struct {
+	Username  string `json:"username"`
+	Platform  string `json:"platform"`
+	Admin     bool   `json:"admin"`
+	SessionId string `json:"session_id"` // 通过gen-token签发时为空
+	LoginAt   int64  `json:"login_at"`   // 通过gen-token签发时为0
+}
+
+type userListAnswer struct {
+	Users []users.Summary `json:"users"`
+}
+
+type sessionListAnswer struct {
+	Sessions []data.Session `json:"sessions"`
+}
+
+type sessionKillAnswer struct {
+	Killed int `json:"killed"`
+}
+
+// issueTokens 为会话签发access token和刷新token，把jti和刷新token的哈希写入session
+func issueTokens(ctx *ctx.CustomContext, session *data.OnlineUser, now time.Time) (*loginAnswer, *defs.CustomError) {
+	cfg := data.Config()
+	expiresAt := now.Add(time.Duration(cfg.Auth.TokenTTL) * time.Second)
+	token, jti, err := middlewares.GenerateToken(session.Username, session.Platform, session.SessionId, expiresAt)
+	if err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	secret := utils.SecureToken(32)
+	session.RefreshHash = utils.SHA256([]byte(secret))
+	session.RefreshedAt = now.Unix()
+	session.ExpiresAt = now.Add(time.Duration(cfg.Auth.RefreshTTL) * time.Second).Unix()
+	session.AccessId, session.AccessExpiresAt = jti, expiresAt.Unix()
+	session.ClientIP, session.UserAgent = ctx.Ctx.ClientIP(), ctx.Ctx.Request.UserAgent()
+	return &loginAnswer{
+		Token:            token,
+		ExpiresAt:        expiresAt.Unix(),
+		RefreshToken:     session.SessionId + "." + secret,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionId:        session.SessionId,
+		Username:         session.Username,
+		Platform:         session.Platform,
+	}, nil
+}
+
+// OnAuthLoginHandler 用户名密码登录，创建新的会话，参数 username password platform(可选，默认配置中的platform)
+func OnAuthLoginHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	username := ctx.GetString("username")
+	platform := ctx.GetString("platform")
+	if platform == "" {
+		platform = data.Config().Platform
+	}
+	user, err := users.Authenticate(data.GUsers, username, ctx.GetString("password"))
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	if user == nil {
+		data.Log().Warn().HttpRequest(ctx.Ctx.Request).Str("username", username).Msg("OnAuthLoginHandler failed")
+		return defs.NewCustomError(defs.ErrCodeLoginFailed, fmt.Errorf("invalid username or password"))
+	}
+
+	now := time.Now()
+	session := &data.OnlineUser{SessionId: utils.SecureToken(16), Platform: platform, Username: user.Username, LoginAt: now.Unix()}
+	answer, cErr := issueTokens(ctx, session, now)
+	if cErr != nil {
+		return cErr
+	}
+	data.GUser.Add(session)
+	ctx.AnswerOK(answer)
+
+	data.Log().Info().Str("username", user.Username).Str("platform", platform).Str("sid", session.SessionId).Msg("OnAuthLoginHandler success")
+	return nil
+}
+
+// OnAuthRefreshHandler 使用刷新token换取新的access token和刷新token，参数 refresh_token；
+// 旧的刷新token立即失效，再次使用时视为被盗用，结束整个会话
+func OnAuthRefreshHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	sessionId, secret, _ := strings.Cut(ctx.GetString("refresh_token"), ".")
+	session := data.GUser.Get(sessionId)
+	if session == nil || secret == "" {
+		return defs.NewCustomError(defs.ErrCodeSessionInvalid, data.ErrSessionNotFound)
+	}
+	if user, err := data.GUsers.Get(session.Username); err != nil {
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	} else if user != nil && user.Disabled {
+		data.KillSession(sessionId)
+		return defs.NewCustomError(defs.ErrCodeSessionInvalid, fmt.Errorf("user is disabled"))
+	}
+
+	now := time.Now()
+	var answer *loginAnswer
+	var cErr *defs.CustomError
+	prev, err := data.GUser.Rotate(sessionId, utils.SHA256([]byte(secret)), now.Unix(), func(u *data.OnlineUser) {
+		data.GRevoked.Revoke(u.AccessId, u.AccessExpiresAt) // 每个会话同时只有一个有效的access token
+		answer, cErr = issueTokens(ctx, u, now)
+	})
+	if errors.Is(err, data.ErrRefreshReused) {
+		data.GRevoked.Revoke(prev.AccessId, prev.AccessExpiresAt)
+		data.Log().Warn().HttpRequest(ctx.Ctx.Request).Str("username", prev.Username).Str("sid", sessionId).Msg("OnAuthRefreshHandler refresh token reused, session killed")
+	}
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeSessionInvalid, err)
+	}
+	if cErr != nil {
+		return cErr
+	}
+	ctx.AnswerOK(answer)
+	return nil
+}
+
+// OnAuthLogoutHandler 退出登录，结束当前会话并吊销本次使用的token
+func OnAuthLogoutHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	if sessionId := ctx.SessionId(); sessionId != "" {
+		data.KillSession(sessionId)
+	}
+	data.GRevoked.Revoke(ctx.TokenId())
+	ctx.AnswerOK(nil)
+
+	data.Log().Info().User(ctx).Str("sid", ctx.SessionId()).Msg("OnAuthLogoutHandler success")
+	return nil
+}
+
+// OnAuthMeHandler 当前token对应的用户和会话
+func OnAuthMeHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	answer := meAnswer{
+		Username:  ctx.Username(),
+		Platform:  ctx.Platform(),
+		Admin:     slices.Contains(data.Config().Admins, ctx.Username()),
+		SessionId: ctx.SessionId(),
+	}
+	if session := data.GUser.Get(answer.SessionId); session != nil {
+		answer.LoginAt = session.LoginAt
+	}
+	ctx.AnswerOK(answer)
+	return nil
+}
+
+// OnAuthSessionListHandler 当前用户的所有会话
+func OnAuthSessionListHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	ctx.AnswerOK(sessionList(ctx.Username(), ctx.SessionId()))
+	return nil
+}
+
+// OnAuthSessionKillHandler 结束当前用户的会话，参数 session_id 或 others(true时结束当前会话以外的所有会话)
+func OnAuthSessionKillHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	username, current := ctx.Username(), ctx.SessionId()
+	killed := 0
+	if ctx.GetBool("others") {
+		for _, session := range data.GUser.GetByUser(username) {
+			if session.SessionId != current && data.KillSession(session.SessionId) {
+				killed++
+			}
+		}
+	} else {
+		sessionId := ctx.GetString("session_id")
+		session := data.GUser.Get(sessionId)
+		if session == nil || session.Username != username {
+			return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("session not found:%s", sessionId))
+		}
+		if data.KillSession(sessionId) {
+			killed++
+		}
+	}
+	ctx.AnswerOK(sessionKillAnswer{Killed: killed})
+
+	data.Log().Info().User(ctx).Int("killed", killed).Msg("OnAuthSessionKillHandler success")
+	return nil
+}
+
+// OnAdminSessionListHandler 查看用户的所有会话，参数 username
+func OnAdminSessionListHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	ctx.AnswerOK(sessionList(ctx.GetString("username"), ""))
+	return nil
+}
+
+// OnAdminSessionKillHandler 结束用户的会话，参数 username session_id(可选，为空时结束该用户的所有会话)
+func OnAdminSessionKillHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	username := ctx.GetString("username")
+	sessionId := ctx.GetString("session_id")
+	killed := 0
+	if sessionId == "" {
+		killed = data.KillUserSessions(username)
+	} else {
+		session := data.GUser.Get(sessionId)
+		if session == nil || session.Username != username {
+			return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("session not found:%s", sessionId))
+		}
+		if data.KillSession(sessionId) {
+			killed++
+		}
+	}
+	ctx.AnswerOK(sessionKillAnswer{Killed: killed})
+
+	data.Log().Info().User(ctx).Str("target", username).Str("sid", sessionId).Int("killed", killed).Msg("OnAdminSessionKillHandler success")
+	return nil
+}
+
+func sessionList(username, current string) sessionListAnswer {
+	sessions := data.GUser.GetByUser(username)
+	answer := sessionListAnswer{Sessions: make([]data.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		answer.Sessions = append(answer.Sessions, session.Session(current))
+	}
+	return answer
+}
+
+// OnAdminUserListHandler 列出所有用户，不返回密码哈希
+func OnAdminUserListHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	list, err := data.GUsers.List()
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	answer := userListAnswer{Users: make([]users.Summary, 0, len(list))}
+	for _, u := range list {
+		answer.Users = append(answer.Users, u.Summary())
+	}
+	ctx.AnswerOK(answer)
+	return nil
+}
+
+// OnAdminUserSaveHandler 新建或修改用户，参数 username password(新建时必填，修改时为空则不变) disabled
+func OnAdminUserSaveHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	username := ctx.GetString("username")
+	password := ctx.GetString("password")
+	if err := users.ValidateUsername(username); err != nil {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	user, err := data.GUsers.Get(username)
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	if user == nil {
+		if password == "" {
+			return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("password is required for new user"))
+		}
+		user = &users.User{Username: username}
+	}
+	if password != "" {
+		if user.PasswordHash, err = users.HashPassword(password); err != nil {
+			return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+		}
+	}
+	user.Disabled = ctx.GetBool("disabled")
+	if err = data.GUsers.Save(user); err != nil {
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	if user.Disabled || password != "" { // 禁用或修改密码后所有设备需要重新登录
+		data.KillUserSessions(username)
+	}
+	ctx.AnswerOK(user.Summary())
+
+	data.Log().Info().User(ctx).Str("target", username).Bool("disabled", user.Disabled).Bool("password", password != "").Msg("OnAdminUserSaveHandler success")
+	return nil
+}
+
+// OnAdminUserDeleteHandler 参数 username
+func OnAdminUserDeleteHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	username := ctx.GetString("username")
+	ok, err := data.GUsers.Delete(username)
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	if !ok {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("user not found:%s", username))
+	}
+	data.KillUserSessions(username)
+	ctx.AnswerOK(nil)
+
+	data.Log().Info().User(ctx).Str("target", username).Msg("OnAdminUserDeleteHandler success")
+	return nil
+}
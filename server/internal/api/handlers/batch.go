@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	ctx "simpletools/internal/api/context"
+	"simpletools/internal/data"
+	"simpletools/internal/defs"
+	"simpletools/internal/translate"
+	"strings"
+)
+
+const batchMaxItems = 500
+
+type batchItem struct {
+	Id      string `json:"id"`
+	Content string `json:"content"`
+}
+
+type batchResult struct {
+	Id      string           `json:"id"`
+	Content string           `json:"content"`          // 失败时为空
+	Code    int              `json:"code"`             // 0成功，其他同接口错误码
+	Error   string           `json:"error,omitempty"`  // 失败原因
+	Missed  []translate.Term `json:"missed,omitempty"` // 译文中没有使用规定译法的术语
+}
+
+type batchAnswer struct {
+	Source  translate.Language `json:"source"` // 未指定时为空
+	Target  translate.Language `json:"target"`
+	Results []batchResult      `json:"results"` // 与items顺序相同
+	Failed  int                `json:"failed"`  // 失败的条数
+}
+
+// parseBatchItems 校验items，id不能为空且不能重复
+func parseBatchItems(cc *ctx.CustomContext) ([]batchItem, error) {
+	var items []batchItem
+	if err := cc.Decode("items", &items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 || len(items) > batchMaxItems {
+		return nil, fmt.Errorf("items count must be in [1, %d]", batchMaxItems)
+	}
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		if item.Id == "" {
+			return nil, fmt.Errorf("item id cannot be empty")
+		}
+		if _, ok := seen[item.Id]; ok {
+			return nil, fmt.Errorf("duplicate item id:%s", item.Id)
+		}
+		seen[item.Id] = struct{}{}
+	}
+	return items, nil
+}
+
+// batchRequest 批量翻译参数，异步执行时序列化后保存在任务中
+type batchRequest struct {
+	Items    []batchItem `json:"items"`
+	Source   string      `json:"source"` // 规范化后的语言代码，为空表示由模型判断
+	Target   string      `json:"target"`
+	Glossary string      `json:"glossary"`
+	NoCache  bool        `json:"no_cache"`
+}
+
+func newBatchRequest(cc *ctx.CustomContext) (*batchRequest, *defs.CustomError) {
+	items, err := parseBatchItems(cc)
+	if err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	source, target, err := parseTranslateLanguages(cc)
+	if err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	req := &batchRequest{Items: items, Source: source.Code, Target: target.Code, Glossary: cc.GetString("glossary"), NoCache: cc.GetBool("no_cache")}
+	if _, _, _, cErr := req.prepare(); cErr != nil { // 异步提交前先校验参数
+		return nil, cErr
+	}
+	return req, nil
+}
+
+// prepare 解析语言和术语表
+func (req *batchRequest) prepare() (source, target translate.Language, terms []translate.Term, cErr *defs.CustomError) {
+	var err error
+	if target, err = translate.ParseLanguage(req.Target); err != nil {
+		cErr = defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+		return
+	}
+	if req.Source != "" {
+		if source, err = translate.ParseLanguage(req.Source); err != nil {
+			cErr = defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+			return
+		}
+	}
+	contents := make([]string, 0, len(req.Items))
+	for _, item := range req.Items {
+		contents = append(contents, item.Content)
+	}
+	terms, cErr = translateGlossary(req.Glossary, strings.Join(contents, "\n"), source, target)
+	return
+}
+
+func (req *batchRequest) run(cl *caller) (any, *defs.CustomError) {
+	source, target, terms, cErr := req.prepare()
+	if cErr != nil {
+		return nil, cErr
+	}
+	items := req.Items
+
+	// 不需要翻译的条目原样返回，不发给提供方
+	var texts []string
+	var indexes []int
+	for i, item := range items {
+		if translate.Translatable(item.Content) {
+			texts = append(texts, item.Content)
+			indexes = append(indexes, i)
+		}
+	}
+	translated, errs := make([]string, 0), make([]*defs.CustomError, 0)
+	if len(texts) > 0 {
+		prompt := translate.BatchPrompt(source, target) + translate.GlossaryPrompt(terms)
+		translated, errs = translateTexts(cl, prompt, texts, req.NoCache)
+	}
+
+	results := make([]batchResult, len(items))
+	for i, item := range items {
+		results[i] = batchResult{Id: item.Id, Content: item.Content}
+	}
+	failed := 0
+	for i, idx := range indexes {
+		if errs[i] != nil {
+			results[idx] = batchResult{Id: items[idx].Id, Code: errs[i].GetCode(), Error: errs[i].GetErr()}
+			failed++
+			continue
+		}
+		results[idx].Content = translated[i]
+		if missed := translate.CheckTerms(translate.TermsIn(terms, items[idx].Content), translated[i]); len(missed) > 0 {
+			results[idx].Missed = missed
+		}
+	}
+
+	data.Log().Info().User(cl).Str("target", target.Code).Int("items", len(items)).Int("translated", len(texts)).Int("failed", failed).Msg("translate batch success")
+	return batchAnswer{Source: source, Target: target, Results: results, Failed: failed}, nil
+}
+
+// OnAIBatchTranslateHandler 批量翻译，参数 items([{id,content}]，最多batchMaxItems条)，target、source、glossary 同 /api/aitranslate
+// 按长度分组后并发请求，每条单独返回结果或错误，部分失败不影响其他条目；async为true时提交为异步任务
+func OnAIBatchTranslateHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	req, cErr := newBatchRequest(ctx)
+	if cErr != nil {
+		return cErr
+	}
+	if ctx.GetBool("async") {
+		return submitJob(ctx, jobKindBatch, req)
+	}
+	result, cErr := req.run(newCaller(ctx))
+	if cErr != nil {
+		return cErr
+	}
+	ctx.AnswerOK(result)
+	return nil
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"fmt"
+	ctx "simpletools/internal/api/context"
+	"simpletools/internal/data"
+	"simpletools/internal/defs"
+	"simpletools/internal/document"
+	"simpletools/internal/llm"
+	"simpletools/internal/translate"
+	"sync"
+)
+
+const (
+	documentMaxSegments = 2000
+	defaultBatchWorkers = 4
+)
+
+type documentFailure struct {
+	Index int    `json:"index"` // 片段序号
+	Text  string `json:"text"`  // 保留原文输出的内容
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+type documentAnswer struct {
+	Content  string             `json:"content"` // 与原文格式相同的译文
+	Format   string             `json:"format"`
+	Source   translate.Language `json:"source"` // 未指定时为空
+	Target   translate.Language `json:"target"`
+	Segments int                `json:"segments"`         // 需要翻译的片段数
+	Failed   []documentFailure  `json:"failed,omitempty"` // 翻译失败的片段，保留原文
+	Missed   []translate.Term   `json:"missed,omitempty"` // 译文中没有使用规定译法的术语
+}
+
+// translateGroup 一次请求翻译一组文本，返回的数量不一致时拆成两半分别重试，返回与texts一一对应的译文和错误
+func translateGroup(cl *caller, prompt string, texts []string, noCache bool) ([]string, []*defs.CustomError) {
+	results := make([]string, len(texts))
+	errs := make([]*defs.CustomError, len(texts))
+	resp, cErr := complete(cl, llm.RouteTranslate, prompt, translate.EncodeBatch(texts), noCache)
+	if cErr != nil {
+		for i := range errs {
+			errs[i] = cErr
+		}
+		return results, errs
+	}
+	list, err := translate.DecodeBatch(resp.Content, len(texts))
+	if err == nil {
+		return list, errs
+	}
+	if len(texts) == 1 {
+		errs[0] = defs.NewCustomError(defs.ErrCodeLLMEmptyAnswer, err)
+		return results, errs
+	}
+	data.Log().Warn().Err(err).Int("count", len(texts)).Msg("translateGroup split")
+	half := len(texts) / 2
+	r1, e1 := translateGroup(cl, prompt, texts[:half], noCache)
+	r2, e2 := translateGroup(cl, prompt, texts[half:], noCache)
+	return append(r1, r2...), append(e1, e2...)
+}
+
+// batchWorkers 单个请求同时发给提供方的请求数
+func batchWorkers() int {
+	if n := data.Config().LLM.BatchWorkers; n > 0 {
+		return n
+	}
+	return defaultBatchWorkers
+}
+
+// translateTexts 保护占位符后按长度分组，由最多batchWorkers个协程并发翻译，译文中的占位符与原文不一致时该条记为失败
+func translateTexts(cl *caller, prompt string, texts []string, noCache bool) ([]string, []*defs.CustomError) {
+	masked := make([]string, len(texts))
+	holders := make([][]string, len(texts))
+	for i, text := range texts {
+		masked[i], holders[i] = translate.Protect(text)
+	}
+	results := make([]string, len(texts))
+	errs := make([]*defs.CustomError, len(texts))
+	groups := translate.Chunk(masked, translate.BatchMaxRunes, translate.BatchMaxItems)
+	sem := make(chan struct{}, batchWorkers())
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(group []int) { // 每组只写自己的下标，不需要加锁
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			batch := make([]string, len(group))
+			for i, idx := range group {
+				batch[i] = masked[idx]
+			}
+			list, batchErrs := translateGroup(cl, prompt, batch, noCache)
+			for i, idx := range group {
+				if errs[idx] = batchErrs[i]; errs[idx] != nil {
+					continue
+				}
+				result, err := translate.Restore(list[i], holders[idx])
+				if err != nil {
+					errs[idx] = defs.NewCustomError(defs.ErrCodeLLMEmptyAnswer, err)
+					continue
+				}
+				results[idx] = result
+			}
+		}(group)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// documentRequest 文档翻译参数，异步执行时序列化后保存在任务中
+type documentRequest struct {
+	Content  string `json:"content"`
+	Format   string `json:"format"`
+	Source   string `json:"source"` // 规范化后的语言代码，为空表示由模型判断
+	Target   string `json:"target"`
+	Glossary string `json:"glossary"`
+	NoCache  bool   `json:"no_cache"`
+}
+
+// documentTask 解析后的文档翻译参数
+type documentTask struct {
+	doc      document.Document
+	source   translate.Language
+	target   translate.Language
+	terms    []translate.Term
+	segments []string
+}
+
+func newDocumentRequest(cc *ctx.CustomContext) (*documentRequest, *defs.CustomError) {
+	req := &documentRequest{Content: cc.GetString("content"), Format: cc.GetString("format"), Glossary: cc.GetString("glossary"), NoCache: cc.GetBool("no_cache")}
+	if req.Format == "" {
+		req.Format = document.FormatFromName(cc.GetString("filename"))
+	}
+	source, target, err := parseTranslateLanguages(cc)
+	if err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	req.Source, req.Target = source.Code, target.Code
+	if _, cErr := req.prepare(); cErr != nil { // 异步提交前先校验参数
+		return nil, cErr
+	}
+	return req, nil
+}
+
+// prepare 解析文档、语言和术语表
+func (req *documentRequest) prepare() (*documentTask, *defs.CustomError) {
+	doc, err := document.Parse(req.Format, req.Content)
+	if err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	task := &documentTask{doc: doc, segments: doc.Segments()}
+	if len(task.segments) > documentMaxSegments {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("document has %d segments, max %d", len(task.segments), documentMaxSegments))
+	}
+	if task.target, err = translate.ParseLanguage(req.Target); err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	if req.Source != "" {
+		if task.source, err = translate.ParseLanguage(req.Source); err != nil {
+			return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+		}
+	}
+	var cErr *defs.CustomError
+	if task.terms, cErr = translateGlossary(req.Glossary, req.Content, task.source, task.target); cErr != nil {
+		return nil, cErr
+	}
+	return task, nil
+}
+
+func (req *documentRequest) run(cl *caller) (any, *defs.CustomError) {
+	task, cErr := req.prepare()
+	if cErr != nil {
+		return nil, cErr
+	}
+	segments := task.segments
+	prompt := translate.BatchPrompt(task.source, task.target) + translate.GlossaryPrompt(task.terms)
+	translated, errs := translateTexts(cl, prompt, segments, req.NoCache)
+	var failed []documentFailure
+	for i, cErr := range errs {
+		if cErr == nil {
+			continue
+		}
+		if len(failed) == len(segments)-1 { // 全部失败时直接返回错误
+			return nil, cErr
+		}
+		translated[i] = segments[i]
+		failed = append(failed, documentFailure{Index: i, Text: segments[i], Code: cErr.GetCode(), Error: cErr.GetErr()})
+	}
+	result, err := task.doc.Render(translated)
+	if err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	missed := translate.CheckTerms(task.terms, result)
+
+	data.Log().Info().User(cl).Str("format", req.Format).Str("target", task.target.Code).Int("segments", len(segments)).Int("failed", len(failed)).
+		Int("missed", len(missed)).Msg("translate document success")
+	return documentAnswer{Content: result, Format: req.Format, Source: task.source, Target: task.target, Segments: len(segments), Failed: failed, Missed: missed}, nil
+}
+
+// OnAIDocumentHandler 保持格式的文档翻译，参数 content、format(markdown/json/yaml/po/srt，为空时根据filename的扩展名判断)、
+// target、source、glossary 同 /api/aitranslate；只翻译文本内容，key、占位符、代码和标记原样保留，失败的片段保留原文并在failed中返回
+// async为true时提交为异步任务，立即返回任务id，通过 /api/jobs/:id 查询结果
+func OnAIDocumentHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	req, cErr := newDocumentRequest(ctx)
+	if cErr != nil {
+		return cErr
+	}
+	if ctx.GetBool("async") {
+		return submitJob(ctx, jobKindDocument, req)
+	}
+	result, cErr := req.run(newCaller(ctx))
+	if cErr != nil {
+		return cErr
+	}
+	ctx.AnswerOK(result)
+	return nil
+}
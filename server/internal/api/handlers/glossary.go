@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	ctx "simpletools/internal/api/context"
+	"simpletools/internal/data"
+	"simpletools/internal/defs"
+	"simpletools/internal/translate"
+)
+
+type glossaryListAnswer struct {
+	Glossaries []translate.GlossarySummary `json:"glossaries"`
+}
+
+// OnGlossaryListHandler 列出所有术语表
+func OnGlossaryListHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	ctx.AnswerOK(glossaryListAnswer{Glossaries: data.GGlossary.List()})
+	return nil
+}
+
+// OnGlossaryGetHandler 参数 name
+func OnGlossaryGetHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	name := ctx.GetString("name")
+	g := data.GGlossary.Get(name)
+	if g == nil {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("glossary not found:%s", name))
+	}
+	ctx.AnswerOK(g)
+	return nil
+}
+
+// OnGlossarySaveHandler 新建或整体替换术语表，参数 name source(可选) target(可选) terms([{source,target}])
+func OnGlossarySaveHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	g := &translate.Glossary{Name: ctx.GetString("name"), Source: ctx.GetString("source"), Target: ctx.GetString("target")}
+	if err := ctx.Decode("terms", &g.Terms); err != nil {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	if err := data.GGlossary.Save(g); err != nil {
+		if errors.Is(err, translate.ErrInvalidGlossary) {
+			return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+		}
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	ctx.AnswerOK(g)
+
+	data.Log().Info().User(ctx).Str("name", g.Name).Int("terms", len(g.Terms)).Msg("OnGlossarySaveHandler success")
+	return nil
+}
+
+// OnGlossaryDeleteHandler 参数 name
+func OnGlossaryDeleteHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	name := ctx.GetString("name")
+	ok, err := data.GGlossary.Delete(name)
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	if !ok {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("glossary not found:%s", name))
+	}
+	ctx.AnswerOK(nil)
+
+	data.Log().Info().User(ctx).Str("name", name).Msg("OnGlossaryDeleteHandler success")
+	return nil
+}
+
+// translateGlossary 取出翻译请求指定的术语表中与原文相关的词条，未指定时返回nil
+func translateGlossary(name, content string, source, target translate.Language) ([]translate.Term, *defs.CustomError) {
+	if name == "" {
+		return nil, nil
+	}
+	g := data.GGlossary.Get(name)
+	if g == nil {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("glossary not found:%s", name))
+	}
+	if !g.Matches(source, target) {
+		return nil, defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("glossary %s is for source %q target %q", name, g.Source, g.Target))
+	}
+	return g.Relevant(content), nil
+}
@@ -1,167 +1,263 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
-	jsoniter "github.com/json-iterator/go"
 	ctx "simpletools/internal/api/context"
 	"simpletools/internal/data"
 	"simpletools/internal/defs"
-	"simpletools/internal/utils"
+	"simpletools/internal/llm"
+	"simpletools/internal/naming"
+	"simpletools/internal/translate"
+	"strings"
 )
 
 const (
-	deepSeekApiUrl    = "https://api.deepseek.com/chat/completions"
-	deepSeekApiKey    = "sk-a773e5fe5edc4616ba955267b5286625"
-	deepSeekChatModel = "deepseek-chat"
-	aiTranslatePrompt = `
-你是一个翻译专家。用户可以向助手发送需要翻译的内容，在用户输入的文本中可能包含多种语言、网络用语、缩写或混合表达登，助手会回答相应的翻译结果，你可以调整语气和风格，并考虑到某些词语的文化内涵和地区差异。同时作为翻译家，需将原文翻译成具有信达雅标准的译文。"信" 即忠实于原文的内容与意图；"达" 意味着译文应通顺易懂，表达清晰；"雅" 则追求译文的文化审美和语言的优美。目标是创作出既忠于原作精神，又符合目标语言文化和读者审美的翻译。本次需要翻译的目标语言为：%s。
-`
-	languageCN = "中文"
-	languageEN = "英文"
-
 	aiNamedPrompt = `
-你是一个经验丰富的编程专家。用户会向你发送一段函数或者变量的描述，你需要根据这段描述准确的给出5个命名结果，5个结果以：1.xxx 2.xxx 3.xxx 4.xxx 5.xxx 这样的形式返回，只需要返回这个格式的内容，不要回复任何多余的内容。本次需要命名的风格为：%s。
+你是一个经验丰富的编程专家。用户会向你发送一段函数或者变量的描述，你需要根据这段描述准确的给出%d个互不相同的命名结果，每行一个，以：1.xxx 2.xxx 这样的形式返回，只需要返回这个格式的内容，不要回复任何多余的内容。本次需要命名的风格为：%s。
 `
-	VarStylePascalCase = "大驼峰"
-	VarStyleCamelCase  = "小驼峰"
-	VarStyleSnakeCase  = "下划线"
-	VarStyleUpperCase  = "全大写下划线常量"
+	aiNamedRetryContent = "%s\n\n以下名称已经给出，请给出与它们不同的名称：%s"
+	namedDefaultCount   = 5
+	namedMaxCount       = 20
 )
 
-type deepSeekApiMessage struct {
-	Role    string `json:"role"`
+type streamDelta struct {
+	Content string `json:"content"`
+}
+
+type streamDone struct {
+	Model        string    `json:"model"`
+	FinishReason string    `json:"finish_reason"`
+	Usage        llm.Usage `json:"usage"`
+	Cached       bool      `json:"cached"`
+	Result       any       `json:"result"` // 与非流式响应的data相同
+}
+
+type aiAnswer struct {
 	Content string `json:"content"`
+	Cached  bool   `json:"cached"` // 结果来自缓存
+}
+
+type translateAnswer struct {
+	Content  string             `json:"content"`
+	Source   translate.Language `json:"source"` // 自动识别失败时code为空
+	Target   translate.Language `json:"target"`
+	Detected bool               `json:"detected"`         // 原文语言由自动识别得到
+	Cached   bool               `json:"cached"`           // 结果来自缓存
+	Terms    []translate.Term   `json:"terms,omitempty"`  // 本次使用的术语表词条
+	Missed   []translate.Term   `json:"missed,omitempty"` // 译文中没有使用规定译法的词条
+}
+
+type namedAnswer struct {
+	Names  []string `json:"names"`  // 已按风格转换并去重的候选名称
+	Cached bool     `json:"cached"` // 结果来自缓存
+}
+
+// SendContentToProvider 按路由选择提供方发送 系统提示词+用户内容
+func SendContentToProvider(reqCtx context.Context, route, systemContent, userContent string, noCache bool) (*llm.ChatResponse, error) {
+	provider := data.GLLM.Get(route)
+	return provider.Chat(reqCtx, llm.NewChatRequest(systemContent, userContent, noCache))
+}
+
+// StreamContentToProvider 流式发送，增量文本通过onDelta回调
+func StreamContentToProvider(reqCtx context.Context, route, systemContent, userContent string, noCache bool, onDelta llm.DeltaFunc) (*llm.ChatResponse, error) {
+	provider := data.GLLM.Get(route)
+	return provider.ChatStream(reqCtx, llm.NewChatRequest(systemContent, userContent, noCache), onDelta)
 }
 
-type deepSeekApiReq struct {
-	Model    string               `json:"model"`
-	Messages []deepSeekApiMessage `json:"messages"`
-	Stream   bool                 `json:"stream"`
+// caller 发起大模型请求的用户及其上下文，异步任务中没有http请求，由任务保存的用户信息构造
+type caller struct {
+	ctx      context.Context
+	username string
+	platform string
+	client   string // 未登录时按来源分别统计用量
 }
 
-type deepSeekApiPromptTokensDetails struct {
-	CachedTokens int `json:"cached_tokens"`
+func newCaller(cc *ctx.CustomContext) *caller {
+	return &caller{ctx: cc.Ctx.Request.Context(), username: cc.Username(), platform: cc.Platform(), client: cc.Client()}
 }
 
-type deepSeekApiUsage struct {
-	PromptTokens         int                            `json:"prompt_tokens"`
-	CompletionTokens     int                            `json:"completion_tokens"`
-	TotalTokens          int                            `json:"total_tokens"`
-	PromptTokensDetails  deepSeekApiPromptTokensDetails `json:"prompt_tokens_details"`
-	PromptCacheHitTokens int                            `json:"prompt_cache_hit_tokens"`
+func (c *caller) Username() string {
+	return c.username
 }
 
-type deepSeekApiLogprobs struct {
-	TokenLogprobs      []float64 `json:"token_logprobs"`
-	TokenTextOffset    []int     `json:"token_text_offset"`
-	SequenceLogprobs   []float64 `json:"sequence_logprobs"`
-	CompletionLogprobs []float64 `json:"completion_logprobs"`
+func (c *caller) Platform() string {
+	return c.platform
 }
 
-type deepSeekApiChoice struct {
-	Index        int                 `json:"index"`
-	Message      deepSeekApiMessage  `json:"message"`
-	FinishReason string              `json:"finish_reason"`
-	Logprobs     deepSeekApiLogprobs `json:"logprobs"`
+func (c *caller) usageUser() string {
+	return data.UsageUser(c.username, c.client)
 }
 
-type deepSeekApiResp struct {
-	ID                string              `json:"id"`
-	Object            string              `json:"object"`
-	Created           int64               `json:"created"`
-	Model             string              `json:"model"`
-	Usage             deepSeekApiUsage    `json:"usage"`
-	SystemFingerprint string              `json:"system_fingerprint"`
-	Choices           []deepSeekApiChoice `json:"choices"`
+// recordUsage 记录本次请求的用量，命中缓存的请求没有消耗token，不计入用量
+func recordUsage(cl *caller, route string, resp *llm.ChatResponse) {
+	if !resp.Cached {
+		data.GUsage.Record(cl.usageUser(), cl.Platform(), resp.Usage)
+	}
+	data.Log().Info().User(cl).Str("route", route).Str("model", resp.Model).Bool("cached", resp.Cached).
+		Int("prompt_tokens", resp.Usage.PromptTokens).Int("completion_tokens", resp.Usage.CompletionTokens).
+		Int("cache_hit_tokens", resp.Usage.CacheHitTokens).Msg("llm usage")
+}
+
+// streamFilter 流式推送前对增量文本做处理，如去掉译文前的原文语言标记
+type streamFilter interface {
+	Filter(delta string) string
+	Flush() string // 流结束时返回仍在缓存中的内容
 }
 
-func SendContentToDeepSeek(systemContent, userContent string) (string, error) {
-	req := deepSeekApiReq{
-		Model:    deepSeekChatModel,
-		Messages: []deepSeekApiMessage{{Role: "system", Content: systemContent}, {Role: "user", Content: userContent}},
-		Stream:   false,
+// complete 非流式请求提供方并记录用量，当日额度用完时不再请求
+func complete(cl *caller, route, systemContent, userContent string, noCache bool) (*llm.ChatResponse, *defs.CustomError) {
+	if data.GUsage.Exhausted(cl.usageUser(), cl.Platform()) {
+		return nil, defs.NewCustomError(defs.ErrCodeQuotaExhausted, fmt.Errorf("daily token quota exhausted"))
 	}
-	bs, err := jsoniter.Marshal(req)
+	resp, err := SendContentToProvider(cl.ctx, route, systemContent, userContent, noCache)
 	if err != nil {
-		return "", err
+		return nil, defs.NewCustomError(llm.ErrCode(err), err)
 	}
-	resp, err := utils.HttpPostWithHeader(deepSeekApiUrl, bs, map[string]string{
-		"Content-Type":  "application/json",
-		"Authorization": "Bearer " + deepSeekApiKey,
+	recordUsage(cl, route, resp)
+	return resp, nil
+}
+
+// chat 同complete，请求参数stream为true时切换为SSE逐段推送增量文本，filter可以为nil
+func chat(cc *ctx.CustomContext, route, systemContent, userContent string, noCache bool, filter streamFilter) (*llm.ChatResponse, *defs.CustomError) {
+	cl := newCaller(cc)
+	if !cc.GetBool("stream") {
+		return complete(cl, route, systemContent, userContent, noCache)
+	}
+	if data.GUsage.Exhausted(cl.usageUser(), cl.Platform()) {
+		return nil, defs.NewCustomError(defs.ErrCodeQuotaExhausted, fmt.Errorf("daily token quota exhausted"))
+	}
+	if !cc.IsStreaming() {
+		cc.StreamStart()
+	}
+	resp, err := StreamContentToProvider(cc.Ctx.Request.Context(), route, systemContent, userContent, noCache, func(delta string) error {
+		if filter != nil {
+			if delta = filter.Filter(delta); delta == "" {
+				return nil
+			}
+		}
+		return cc.StreamEvent(ctx.StreamEventDelta, streamDelta{Content: delta})
 	})
+	if err == nil && filter != nil {
+		if rest := filter.Flush(); rest != "" {
+			_ = cc.StreamEvent(ctx.StreamEventDelta, streamDelta{Content: rest})
+		}
+	}
 	if err != nil {
-		return "", err
+		return nil, defs.NewCustomError(llm.ErrCode(err), err)
 	}
-	return string(resp), nil
+	recordUsage(cl, route, resp)
+	return resp, nil
 }
 
-func GetResultFromDeepSeekResp(resp string) (string, error) {
-	rlt := &deepSeekApiResp{}
-	if err := jsoniter.UnmarshalFromString(resp, &rlt); err != nil {
-		return "", err
+// answer 非流式时一次性返回result；流式时发送结束事件，携带用量、结束原因和result
+func answer(cc *ctx.CustomContext, resp *llm.ChatResponse, result any) {
+	if !cc.IsStreaming() {
+		cc.AnswerOK(result)
+		return
 	}
-	result := rlt.Choices[0].Message.Content
-	return result, nil
+	_ = cc.StreamEvent(ctx.StreamEventDone, streamDone{Model: resp.Model, FinishReason: resp.FinishReason, Usage: resp.Usage, Cached: resp.Cached, Result: result})
 }
 
-func GetTranslatePrompt(language int64) string {
-	switch defs.LanguageType(language) {
-	case defs.LanguageTypeChinese:
-		return fmt.Sprintf(aiTranslatePrompt, languageCN)
-	case defs.LanguageTypeEnglish:
-		return fmt.Sprintf(aiTranslatePrompt, languageEN)
+// parseTranslateLanguages 解析原文和目标语言，target为空时兼容旧的language参数，source为空表示需要自动识别
+func parseTranslateLanguages(cc *ctx.CustomContext) (source, target translate.Language, err error) {
+	if code := cc.GetString("target"); code != "" {
+		target, err = translate.ParseLanguage(code)
+	} else {
+		target, err = translate.FromLanguageType(defs.LanguageType(cc.GetInt64("language")))
+	}
+	if err != nil {
+		return
+	}
+	if code := cc.GetString("source"); code != "" {
+		source, err = translate.ParseLanguage(code)
 	}
-	return fmt.Sprintf(aiTranslatePrompt, languageCN)
+	return
 }
 
+// OnAITranslateHandler 参数 content、target(BCP-47，兼容旧参数language)、source(BCP-47，为空时自动识别并在结果中返回)、
+// glossary(可选，术语表名称，原文中出现的词条要求按规定译法翻译，并在结果中返回未遵守的词条)
 func OnAITranslateHandler(ctx *ctx.CustomContext) *defs.CustomError {
 	content := ctx.GetString("content")
-	language := ctx.GetInt64("language")
-
-	resp, err := SendContentToDeepSeek(GetTranslatePrompt(language), content)
+	source, target, err := parseTranslateLanguages(ctx)
 	if err != nil {
-		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	terms, cErr := translateGlossary(ctx.GetString("glossary"), content, source, target)
+	if cErr != nil {
+		return cErr
 	}
 
-	result, err := GetResultFromDeepSeekResp(resp)
-	if err != nil {
-		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	detect := source.IsZero()
+	var filter streamFilter
+	if detect {
+		filter = &translate.SourceFilter{}
 	}
+	prompt := translate.Prompt(source, target) + translate.GlossaryPrompt(terms)
+	resp, cErr := chat(ctx, llm.RouteTranslate, prompt, content, ctx.GetBool("no_cache"), filter)
+	if cErr != nil {
+		return cErr
+	}
+	result := resp.Content
+	if detect {
+		code, rest, _ := translate.ExtractSource(result)
+		result = rest
+		if source, err = translate.ParseLanguage(code); err != nil { // 模型没有按要求给出语言时根据文字系统判断，仍无法判断时为空
+			source = translate.GuessLanguage(content)
+		}
+	}
+	missed := translate.CheckTerms(terms, result)
+	answer(ctx, resp, translateAnswer{Content: result, Source: source, Target: target, Detected: detect, Cached: resp.Cached, Terms: terms, Missed: missed})
 
-	ctx.AnswerOK(result)
-	data.Log().Info().Str("content", content).Str("result", result).Msg("OnAITranslateHandler success")
+	data.Log().Info().Str("content", content).Str("result", result).Str("source", source.Code).Str("target", target.Code).Bool("cached", resp.Cached).
+		Int("terms", len(terms)).Int("missed", len(missed)).Msg("OnAITranslateHandler success")
 	return nil
 }
 
-func GetNamedPrompt(style int64) string {
-	switch defs.VariableType(style) {
-	case defs.VariableTypePascalCase:
-		return fmt.Sprintf(aiNamedPrompt, VarStylePascalCase)
-	case defs.VariableTypeCamelCase:
-		return fmt.Sprintf(aiNamedPrompt, VarStyleCamelCase)
-	case defs.VariableTypeSnakeCase:
-		return fmt.Sprintf(aiNamedPrompt, VarStyleSnakeCase)
-	case defs.VariableTypeUpperCase:
-		return fmt.Sprintf(aiNamedPrompt, VarStyleUpperCase)
-	}
-	return fmt.Sprintf(aiNamedPrompt, VarStylePascalCase)
+func GetNamedPrompt(conv naming.Convention, count int) string {
+	return fmt.Sprintf(aiNamedPrompt, count, conv.Describe())
 }
 
+// OnAINamedHandler 解析模型给出的候选名称，按命名规则统一大小写并去重，数量不足时换一批再请求一次
+// 参数 style 强制指定风格；未指定时由 target_language(go/typescript/python/rust/sql/css) 和 kind(function/variable/constant/type/file/column/flag/config_key) 推导，
+// exported 表示Go导出名称
 func OnAINamedHandler(ctx *ctx.CustomContext) *defs.CustomError {
 	content := ctx.GetString("content")
 	style := ctx.GetInt64("style")
-
-	resp, err := SendContentToDeepSeek(GetNamedPrompt(style), content)
+	count := int(ctx.GetInt64("count"))
+	if count == 0 {
+		count = namedDefaultCount
+	}
+	if count < 1 || count > namedMaxCount {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("count must be in [1, %d]", namedMaxCount))
+	}
+	conv, err := naming.NewConvention(defs.VariableType(style), ctx.GetString("target_language"), ctx.GetString("kind"), ctx.GetBool("exported"))
 	if err != nil {
-		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
 	}
 
-	result, err := GetResultFromDeepSeekResp(resp)
-	if err != nil {
-		return defs.NewCustomError(defs.ErrCodeSystemError, err)
+	prompt := GetNamedPrompt(conv, count)
+	resp, cErr := chat(ctx, llm.RouteNamed, prompt, content, ctx.GetBool("no_cache"), nil)
+	if cErr != nil {
+		return cErr
+	}
+	candidates := naming.ParseCandidates(resp.Content)
+	names := naming.Normalize(candidates, conv, count)
+	if len(names) < count && !ctx.IsStreaming() { // 流式时增量已经发给客户端，不再重试
+		retryContent := fmt.Sprintf(aiNamedRetryContent, content, strings.Join(names, ", "))
+		retry, cErr := chat(ctx, llm.RouteNamed, prompt, retryContent, true, nil)
+		if cErr == nil {
+			candidates = append(candidates, naming.ParseCandidates(retry.Content)...)
+			names = naming.Normalize(candidates, conv, count)
+		} else {
+			data.Log().Warn().CErr(cErr).Msg("OnAINamedHandler retry failed")
+		}
+	}
+	if len(names) == 0 {
+		return defs.NewCustomError(defs.ErrCodeLLMEmptyAnswer, fmt.Errorf("no valid name in answer: %s", resp.Content))
 	}
+	answer(ctx, resp, namedAnswer{Names: names, Cached: resp.Cached})
 
-	ctx.AnswerOK(result)
-	data.Log().Info().Str("content", content).Str("result", result).Msg("OnAINamedHandler success")
+	data.Log().Info().Str("content", content).Strs("names", names).Bool("cached", resp.Cached).Msg("OnAINamedHandler success")
 	return nil
 }
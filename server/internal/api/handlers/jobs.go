@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	ctx "simpletools/internal/api/context"
+	"simpletools/internal/data"
+	"simpletools/internal/defs"
+	"simpletools/internal/jobs"
+)
+
+const (
+	jobKindDocument = "translate_document"
+	jobKindBatch    = "translate_batch"
+)
+
+// jobRequest 可以异步执行的请求参数
+type jobRequest interface {
+	run(cl *caller) (any, *defs.CustomError)
+}
+
+func init() {
+	jobs.Register(jobKindDocument, func(c context.Context, job *jobs.Job) (any, *defs.CustomError) {
+		return runJob(c, job, &documentRequest{})
+	})
+	jobs.Register(jobKindBatch, func(c context.Context, job *jobs.Job) (any, *defs.CustomError) {
+		return runJob(c, job, &batchRequest{})
+	})
+}
+
+// runJob 还原提交时保存的参数，以提交任务的用户身份执行
+func runJob(c context.Context, job *jobs.Job, req jobRequest) (any, *defs.CustomError) {
+	if err := jsoniter.Unmarshal(job.Params, req); err != nil {
+		return nil, defs.NewCustomError(defs.ErrCodeSystemError, err)
+	}
+	return req.run(&caller{ctx: c, username: job.Username, platform: job.Platform, client: job.Client})
+}
+
+type jobAnswer struct {
+	Id     string      `json:"id"`
+	Status jobs.Status `json:"status"`
+}
+
+// submitJob 登记任务后交给主循环分发，立即返回任务id
+func submitJob(cc *ctx.CustomContext, kind string, req jobRequest) *defs.CustomError {
+	job, err := data.GJobs.Create(kind, cc.Username(), cc.Platform(), cc.Client(), req)
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	if !data.GSink.TrySend(defs.EssJob, job) { // 主循环繁忙或进程正在退出，不阻塞请求协程，撤销任务后让客户端稍后重试
+		data.GJobs.Remove(job.Id)
+		data.Log().Warn().User(cc).Str("id", job.Id).Msg("submit job when event sink is full or closed")
+		cc.Ctx.AbortWithStatus(http.StatusServiceUnavailable)
+		return nil
+	}
+	cc.AnswerOK(jobAnswer{Id: job.Id, Status: jobs.StatusQueued})
+
+	data.Log().Info().User(cc).Str("id", job.Id).Str("kind", kind).Msg("submit job success")
+	return nil
+}
+
+// OnJobGetHandler 查询自己提交的任务，结束后result与同步接口的data相同
+func OnJobGetHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	id := ctx.Ctx.Param("id")
+	view, ok := data.GJobs.Get(id, ctx.Username(), ctx.Platform(), ctx.Client())
+	if !ok {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("job not found:%s", id))
+	}
+	ctx.AnswerOK(view)
+	return nil
+}
+
+// OnJobCancelHandler 取消排队中或执行中的任务
+func OnJobCancelHandler(ctx *ctx.CustomContext) *defs.CustomError {
+	id := ctx.Ctx.Param("id")
+	ok, err := data.GJobs.Cancel(id, ctx.Username(), ctx.Platform(), ctx.Client())
+	if err != nil {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, err)
+	}
+	if !ok {
+		return defs.NewCustomError(defs.ErrCodeRequestParamsErr, fmt.Errorf("job already finished:%s", id))
+	}
+	ctx.AnswerOK(nil)
+
+	data.Log().Info().User(ctx).Str("id", id).Msg("OnJobCancelHandler success")
+	return nil
+}
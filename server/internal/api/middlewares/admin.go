@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"simpletools/internal/data"
+	"simpletools/internal/defs"
+	"slices"
+)
+
+// Admin 只允许配置中的管理员访问，需要放在Validate(true)之后，依赖jwt中的用户名
+func Admin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("username")
+		if username == "" || !slices.Contains(data.Config().Admins, username) {
+			data.Log().Warn().HttpRequest(c.Request).Str("username", username).Msg("admin request denied")
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{"code": defs.ErrCodePermissionDenied, "msg": "permission denied", "data": nil})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"simpletools/internal/data"
+	"simpletools/lib/reqsign"
+)
+
+// Login 需要放在Validate(false)之后，带X-Authorization时解析出用户，auth.require_login开启时必须带有效的token
+func Login() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jwtToken := c.GetHeader(reqsign.HeaderAuthorization)
+		if jwtToken == "" && !data.Config().Auth.RequireLogin {
+			c.Next()
+			return
+		}
+		if !validateJwtToken(c, jwtToken) || disabled(c.GetString("username")) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			data.Log().Warn().HttpRequest(c.Request).Str("username", c.GetString("username")).Msg("login required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// disabled 用户已在用户存储中被禁用，不在存储中的用户(如gen-token签发)不受影响
+func disabled(username string) bool {
+	user, err := data.GUsers.Get(username)
+	return err == nil && user != nil && user.Disabled
+}
@@ -4,11 +4,21 @@ import (
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"simpletools/internal/data"
+	"slices"
 )
 
+// Cors 允许的来源每次请求从配置读取，支持热加载；不在列表中的来源不返回跨域头，由浏览器拦截
 func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		origins := data.Config().CorsOrigins
+		if len(origins) == 0 || slices.Contains(origins, "*") {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); origin != "" && slices.Contains(origins, origin) {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		if c.Request.Method == http.MethodOptions {
@@ -7,11 +7,16 @@ import (
 	"simpletools/internal/data"
 )
 
+// Exit 退出期间拒绝新的请求，并统计正在处理的请求数
 func Exit() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if data.GIsExiting.Load() { // 服务器正在退出，拒绝新的请求，记录相关url参数及和body的内容
 			data.Log().Warn().HttpRequest(c.Request).Msg(fmt.Sprintf("http recv request when server exit"))
 			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
 		}
+		data.GInflight.Add(1)
+		defer data.GInflight.Add(-1)
+		c.Next()
 	}
 }
@@ -1,113 +1,228 @@
 package middlewares
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v4"
+	"io"
 	"net/http"
+	ctx "simpletools/internal/api/context"
+	"simpletools/internal/configs"
 	"simpletools/internal/data"
+	"simpletools/internal/nonce"
+	"simpletools/internal/utils"
+	"simpletools/lib/reqsign"
 	"strconv"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	nonceExpiration = 5 * time.Minute // 时间窗口，用于防止重放攻击
-
 	errCodeOk            = 0
 	errCodeParamsInvalid = 1
 	errCodeJwtInvalid    = 2
 	errCodeTsInvalid     = 3
 	errCodeNonceInvalid  = 4
+	errCodeSignInvalid   = 5
+	errCodeBodyTooLarge  = 6
+	errCodeNonceFull     = 7
 )
 
 type CustomClaims struct {
 	jwt.StandardClaims
-	Username string `json:"username"`
-	Platform string `json:"platform"`
+	Username  string `json:"username"`
+	Platform  string `json:"platform"`
+	SessionId string `json:"sid,omitempty"` // 登录会话id，gen-token签发的token没有会话
 }
 
 var (
-	usedNonces = sync.Map{}
-	jwtKey     = []byte("BFA31985-E2C7-4F6D-8A9B-73D5109E8C3A") // 不暴露给客户端，只在服务器使用
+	keyrings atomic.Pointer[keyringCache]
+
+	// validMethods 只接受这两种算法，每个kid还要求与配置的算法一致，防止用公钥当作HMAC密钥等算法混淆
+	validMethods = []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
 )
 
-func GenerateToken(username, platform string) (string, error) {
+// keyringCache 密钥不暴露给客户端，只在服务器使用；按配置对象缓存，热加载替换配置后重新解析
+type keyringCache struct {
+	cfg  *configs.ServerConfig
+	ring *configs.Keyring
+}
+
+func keyring() (*configs.ServerConfig, *configs.Keyring, error) {
+	cfg := data.Config()
+	if cache := keyrings.Load(); cache != nil && cache.cfg == cfg {
+		return cfg, cache.ring, nil
+	}
+	ring, err := cfg.Keyring() // 加载配置时已经校验过，这里不会失败
+	if err != nil {
+		return nil, nil, err
+	}
+	keyrings.Store(&keyringCache{cfg: cfg, ring: ring})
+	return cfg, ring, nil
+}
+
+// GenerateToken 使用jwt.active_kid对应的密钥签发token并返回jti，有效期由调用方决定，登录和刷新时使用auth.token_ttl
+func GenerateToken(username, platform, sessionId string, expiresAt time.Time) (string, string, error) {
+	cfg, ring, err := keyring()
+	if err != nil {
+		return "", "", err
+	}
+	jti := utils.SecureToken(16)
 	claims := CustomClaims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 4).Unix(),
-			Issuer:    "simpletools-admin",
+			Id:        jti,
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: expiresAt.Unix(),
+			Issuer:    cfg.Jwt.Issuer,
+			Audience:  cfg.Jwt.Audience,
 		},
-		Username: username,
-		Platform: platform,
+		Username:  username,
+		Platform:  platform,
+		SessionId: sessionId,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims) // 对称加密、单服验证
-	return token.SignedString(jwtKey)
+	token := jwt.NewWithClaims(ring.Active.Method, claims)
+	if ring.Active.Kid != "" {
+		token.Header["kid"] = ring.Active.Kid
+	}
+	signed, err := token.SignedString(ring.Active.Sign)
+	return signed, jti, err
 }
 
-func validateJwtToken(c *gin.Context, jwtToken string) bool {
-	token, err := jwt.Parse(jwtToken, func(tk *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+// parseToken 按kid选择密钥并校验算法、签名、过期时间、iss和aud，不检查吊销
+func parseToken(jwtToken string) (*CustomClaims, error) {
+	cfg, ring, err := keyring()
 	if err != nil {
-		return false
+		return nil, err
+	}
+	claims := &CustomClaims{}
+	_, err = jwt.ParseWithClaims(jwtToken, claims, func(tk *jwt.Token) (interface{}, error) {
+		kid, _ := tk.Header["kid"].(string)
+		key, ok := ring.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid:%q", kid)
+		}
+		if tk.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("kid %q expects alg %s, got %s", kid, key.Method.Alg(), tk.Method.Alg())
+		}
+		return key.Verify, nil
+	}, jwt.WithValidMethods(validMethods))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case claims.ExpiresAt == 0:
+		return nil, fmt.Errorf("token has no exp")
+	case !claims.VerifyIssuer(cfg.Jwt.Issuer, true):
+		return nil, fmt.Errorf("token issuer mismatch:%s", claims.Issuer)
+	case !claims.VerifyAudience(cfg.Jwt.Audience, true):
+		return nil, fmt.Errorf("token audience mismatch:%s", claims.Audience)
+	case claims.Username == "":
+		return nil, fmt.Errorf("token has no username")
 	}
-	if !token.Valid {
+	return claims, nil
+}
+
+func validateJwtToken(c *gin.Context, jwtToken string) bool {
+	claims, err := parseToken(jwtToken)
+	if err != nil {
+		data.Log().Debug().Err(err).Msg("jwt token invalid")
 		return false
 	}
-	claims, ok := token.Claims.(jwt.MapClaims) // CustomClaims的jwt.StandardClaims匿名继承则实现了Valid接口，则Claims是jwt.MapClaims类型
-	if !ok {
+	if claims.Id != "" && data.GRevoked.Revoked(claims.Id) { // 已退出登录或会话被结束
 		return false
 	}
 	// 在后续请求中直接获取用户信息
-	c.Set("username", claims["username"])
-	c.Set("platform", claims["platform"])
+	c.Set("username", claims.Username)
+	c.Set("platform", claims.Platform)
+	c.Set("sid", claims.SessionId)
+	c.Set("jti", claims.Id)
+	c.Set("exp", claims.ExpiresAt)
 	return true
 }
 
-// validate 由https保证请求过程中的内容不会被篡改和窃取
+// validate 时间戳和nonce防止重放，签名把它们与请求内容绑定；由https保证请求过程中的内容不会被窃取
 func validate(c *gin.Context, checkJwt bool) int {
-	nonce := c.GetHeader("X-Nonce")
-	timestamp := c.GetHeader("X-Timestamp")
-	if nonce == "" || timestamp == "" {
+	id := c.GetHeader(reqsign.HeaderNonce)
+	timestamp := c.GetHeader(reqsign.HeaderTimestamp)
+	if id == "" || timestamp == "" {
 		return errCodeParamsInvalid
 	}
+	cfg := data.Config().RequestSign
+	skew := int64(cfg.Skew)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	now := time.Now().Unix()
+	if err != nil || now-ts > skew || ts-now > skew { // 超前的时间戳同样拒绝，否则可以预先生成在窗口外仍然有效的请求
+		return errCodeTsInvalid
+	}
+	if code := verifySign(c, &cfg, timestamp, id); code != errCodeOk {
+		return code
+	}
 	if checkJwt {
-		jwtToken := c.GetHeader("X-Authorization")
+		jwtToken := c.GetHeader(reqsign.HeaderAuthorization)
 		if !validateJwtToken(c, jwtToken) {
 			return errCodeJwtInvalid
 		}
 	}
-	ts, _ := strconv.Atoi(timestamp)
-	if time.Now().Unix()-int64(ts) > int64(nonceExpiration.Seconds()) {
-		return errCodeTsInvalid
-	}
-	if _, ok := usedNonces.Load(nonce); ok {
+	// 签名通过后才记录nonce，避免伪造的请求占用nonce；时间戳超出窗口后请求本身会被拒绝，nonce可以清理
+	// 按签名校验通过的客户端分区，未签名的请求按来源ip分区，单个来源不能挤占其他客户端的容量
+	switch err = data.GNonces.Use(ctx.ClientKey(c), id, ts+skew); {
+	case errors.Is(err, nonce.ErrReplay):
 		return errCodeNonceInvalid
+	case err != nil:
+		return errCodeNonceFull
 	}
-	usedNonces.Store(nonce, time.Now())
 	return errCodeOk
 }
 
-func Validate(checkJwt bool) gin.HandlerFunc {
-	// 启动一个后台协程来定期清理过期的 nonce
-	go func() {
-		for {
-			time.Sleep(nonceExpiration)
-			now := time.Now()
-			usedNonces.Range(func(key, value interface{}) bool {
-				if timestamp, ok := value.(time.Time); ok {
-					if now.Sub(timestamp) > nonceExpiration {
-						usedNonces.Delete(key)
-					}
-				}
-				return true
-			})
+// verifySign 带有X-Signature或配置要求签名时校验，读取的请求体放回Request.Body供后续handler解析
+func verifySign(c *gin.Context, cfg *configs.RequestSignConfig, timestamp, nonce string) int {
+	signature := c.GetHeader(reqsign.HeaderSignature)
+	if signature == "" {
+		if cfg.Required {
+			return errCodeSignInvalid
+		}
+		return errCodeOk
+	}
+	clientId := c.GetHeader(reqsign.HeaderClient)
+	secret, ok := cfg.Secret(clientId)
+	if !ok {
+		return errCodeSignInvalid
+	}
+	var body []byte
+	if c.Request.Body != nil {
+		limit := int64(cfg.MaxBody) << 20
+		var err error
+		if body, err = io.ReadAll(io.LimitReader(c.Request.Body, limit+1)); err != nil {
+			return errCodeParamsInvalid
 		}
-	}()
+		if int64(len(body)) > limit {
+			return errCodeBodyTooLarge
+		}
+		_ = c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if !reqsign.Verify(secret, signature, c.Request.Method, c.Request.URL.RequestURI(), timestamp, nonce, body) {
+		return errCodeSignInvalid
+	}
+	c.Set("client", clientId)
+	return errCodeOk
+}
+
+// Validate nonce由data.GNonces统一记录和清理，可以在多个路由组中使用
+func Validate(checkJwt bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if code := validate(c, checkJwt); code != 0 {
-			c.AbortWithStatus(http.StatusUnauthorized)
-			data.Log().Error().HttpRequest(c.Request).Int("code", code).Msg("request sign failed")
+			status := http.StatusUnauthorized
+			switch code {
+			case errCodeBodyTooLarge:
+				status = http.StatusRequestEntityTooLarge
+			case errCodeNonceFull:
+				status = http.StatusServiceUnavailable
+			}
+			c.AbortWithStatus(status)
+			data.Log().Error().HttpRequest(c.Request).Int("code", code).Str("client", c.GetHeader(reqsign.HeaderClient)).Msg("request sign failed")
 			return
 		}
 		c.Next()
@@ -0,0 +1,183 @@
+package middlewares
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"simpletools/internal/configs"
+	"simpletools/internal/data"
+	"testing"
+	"time"
+)
+
+const (
+	testJwtKey = "legacy-jwt-key-0123456789"
+	testHsKey  = "hs1-jwt-key-0123456789"
+)
+
+// setupKeyring 配置jwt_key(kid为空)、HS256的hs1和EdDSA的ed1，ed1为签发密钥
+func setupKeyring(t *testing.T) (ed25519.PrivateKey, ed25519.PublicKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := configs.Default()
+	cfg.JwtKey = testJwtKey
+	cfg.Jwt.ActiveKid = "ed1"
+	cfg.Jwt.Keys = []configs.JwtKeyConfig{
+		{Kid: "hs1", Alg: "HS256", Key: testHsKey},
+		{Kid: "ed1", Alg: "EdDSA", Key: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))},
+	}
+	data.GConfig.Store(cfg)
+	return private, public
+}
+
+func testClaims(modify func(c *CustomClaims)) CustomClaims {
+	cfg := configs.Default()
+	c := CustomClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        "jti",
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			Issuer:    cfg.Jwt.Issuer,
+			Audience:  cfg.Jwt.Audience,
+		},
+		Username: "alice",
+		Platform: "web",
+	}
+	if modify != nil {
+		modify(&c)
+	}
+	return c
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims CustomClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestParseToken(t *testing.T) {
+	private, public := setupKeyring(t)
+	_, otherPrivate, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := []struct {
+		name  string
+		token func() string
+		ok    bool
+	}{
+		{"legacy jwt_key", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "", []byte(testJwtKey), testClaims(nil))
+		}, true},
+		{"hs256 kid", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "hs1", []byte(testHsKey), testClaims(nil))
+		}, true},
+		{"eddsa kid", func() string {
+			return signToken(t, jwt.SigningMethodEdDSA, "ed1", private, testClaims(nil))
+		}, true},
+		{"forged hs256 signature", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "hs1", []byte("forged-key-0123456789"), testClaims(nil))
+		}, false},
+		{"forged eddsa signature", func() string {
+			return signToken(t, jwt.SigningMethodEdDSA, "ed1", otherPrivate, testClaims(nil))
+		}, false},
+		{"expired", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "", []byte(testJwtKey), testClaims(func(c *CustomClaims) {
+				c.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+			}))
+		}, false},
+		{"no exp", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "", []byte(testJwtKey), testClaims(func(c *CustomClaims) {
+				c.ExpiresAt = 0
+			}))
+		}, false},
+		{"alg none", func() string {
+			return signToken(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, testClaims(nil))
+		}, false},
+		{"alg hs384", func() string {
+			return signToken(t, jwt.SigningMethodHS384, "", []byte(testJwtKey), testClaims(nil))
+		}, false},
+		{"hs256 with eddsa public key as secret", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "ed1", []byte(public), testClaims(nil))
+		}, false},
+		{"eddsa on hs256 kid", func() string {
+			return signToken(t, jwt.SigningMethodEdDSA, "hs1", private, testClaims(nil))
+		}, false},
+		{"eddsa without kid", func() string {
+			return signToken(t, jwt.SigningMethodEdDSA, "", private, testClaims(nil))
+		}, false},
+		{"unknown kid", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "hs2", []byte(testHsKey), testClaims(nil))
+		}, false},
+		{"kid of another key", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "hs1", []byte(testJwtKey), testClaims(nil))
+		}, false},
+		{"issuer mismatch", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "", []byte(testJwtKey), testClaims(func(c *CustomClaims) {
+				c.Issuer = "other"
+			}))
+		}, false},
+		{"audience mismatch", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "", []byte(testJwtKey), testClaims(func(c *CustomClaims) {
+				c.Audience = "other"
+			}))
+		}, false},
+		{"no audience", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "", []byte(testJwtKey), testClaims(func(c *CustomClaims) {
+				c.Audience = ""
+			}))
+		}, false},
+		{"no username", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "", []byte(testJwtKey), testClaims(func(c *CustomClaims) {
+				c.Username = ""
+			}))
+		}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := parseToken(tc.token())
+			if tc.ok && (err != nil || claims.Username != "alice") {
+				t.Fatalf("want valid token, got claims:%v err:%v", claims, err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("want invalid token, got nil error")
+			}
+		})
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	setupKeyring(t)
+	token, jti, err := GenerateToken("alice", "web", "sid", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := parseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Id != jti || claims.SessionId != "sid" || claims.Platform != "web" {
+		t.Fatalf("claims %+v do not match jti:%s", claims, jti)
+	}
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &CustomClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "ed1" || parsed.Method.Alg() != "EdDSA" {
+		t.Fatalf("header %v, want kid ed1 and alg EdDSA", parsed.Header)
+	}
+}
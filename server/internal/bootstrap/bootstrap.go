@@ -1,52 +1,56 @@
 package bootstrap
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"io"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"simpletools/internal/configs"
 	"simpletools/internal/data"
 	"simpletools/internal/defs"
+	"simpletools/internal/pidfile"
 	"simpletools/lib/logger"
-	"strconv"
 	"sync"
 	"syscall"
 	"time"
 )
 
 var (
-	wgBootstrap     sync.WaitGroup
-	LastChangedDate string
-	pidFile         string
+	wgBootstrap sync.WaitGroup
+	pidFile     string
+	pidLock     *pidfile.PidFile // 启动成功后持有，启动被拒绝时为nil，退出时不会删除其他进程的pid文件
+	stalePid    int              // 上次异常退出遗留的pid，日志初始化后记录
+	httpServer  *http.Server
 )
 
-func initConst(pidFileInput string) {
-	pidFile = pidFileInput
-}
-
-func savePid() error {
-	f, err := os.OpenFile(pidFile, os.O_CREATE|os.O_RDWR, 0600|0644)
+// AcquirePid 在加载缓存、会话和任务以及监听端口之前调用，已有进程运行时直接返回错误，不会读写其他进程的数据
+func AcquirePid(cfg *configs.ServerConfig) error {
+	pidFile = cfg.PidFile
+	lock, stale, err := pidfile.Acquire(pidFile)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	if content, _ := io.ReadAll(f); len(content) > 0 { // 进程已经启动了，不允许重复启动
-		return fmt.Errorf("process already running in pid:%s", string(content))
-	}
+	pidLock, stalePid = lock, stale
+	return nil
+}
 
-	pid := strconv.Itoa(os.Getpid())
-	if _, err = f.Write([]byte(pid)); err != nil {
-		return fmt.Errorf("process write pid:%s failed", pid)
+func logPid() {
+	if stalePid > 0 {
+		data.Log().Warn().Int("stale_pid", stalePid).Str("path", pidFile).Msg("replace stale pid file")
 	}
-	data.Log().Info().Str("pid", pid).Msg("save pid file")
-	return nil
+	data.Log().Info().Int("pid", os.Getpid()).Str("path", pidFile).Msg("save pid file")
 }
 
 func dropPid() {
-	err := os.Remove(pidFile)
+	if pidLock == nil {
+		return
+	}
+	err := pidLock.Release()
+	pidLock = nil
 	data.Log().Info().Err(err).Msg("drop pid file")
 }
 
@@ -61,6 +65,23 @@ func update() {
 	if data.GPollerLogFlush.PassedTime(now, 30) { // 30秒写入一次日志，避免日志长时间在内存中不进入文件
 		logger.Flush()
 	}
+	if data.GPollerJobClean.PassedTime(now, 60) {
+		data.GJobs.Clean(now)
+	}
+	if data.GPollerNonce.PassedTime(now, 10) { // 请求中也会顺带清理，这里处理长时间没有请求的客户端
+		data.GNonces.Clean(time.Now().Unix())
+	}
+	if data.GPollerSession.PassedTime(now, 60) { // token过期按真实时间判断，不使用逻辑时间
+		ts := time.Now().Unix()
+		data.GUser.Clean(ts)
+		data.GRevoked.Clean(ts)
+	}
+	if data.GRemote != nil && data.GPollerRemote.PassedTime(now, data.GRemote.Interval()) {
+		data.GRemote.Refresh()
+	}
+	if watch := data.Config().ConfigWatch; watch > 0 && data.GPollerConfig.PassedTime(now, int64(watch)) && data.ConfigModified() {
+		_ = data.ReloadConfig("file changed")
+	}
 }
 
 func preExit() {
@@ -70,15 +91,47 @@ func preExit() {
 			data.Log().Error().Msg(tips)
 		}
 	}()
+	data.GJobs.Shutdown(defs.ExitMode(data.GExitMode.Load()))
+	if err := data.GLLM.SaveCache(); err != nil {
+		data.Log().Error().Err(err).Msg("save llm cache failed")
+	}
+	if err := data.SaveUsage(); err != nil {
+		data.Log().Error().Err(err).Msg("save usage failed")
+	}
+	if err := data.SaveSessions(); err != nil {
+		data.Log().Error().Err(err).Msg("save sessions failed")
+	}
+	if err := data.GNonces.Close(); err != nil {
+		data.Log().Error().Err(err).Msg("close nonce store failed")
+	}
 	dropPid()
 }
 
-func Bootstrap(pidFileInput string, r *gin.Engine) {
-	if len(os.Args) > 1 && os.Args[1] == "-v" {
-		fmt.Println("Last Changed Date: ", LastChangedDate)
-		os.Exit(0)
+// shutdownHttp 正常退出时停止监听，等待正在处理的请求完成，最多等待ShutdownWait秒后强制关闭连接；其他退出方式直接关闭
+func shutdownHttp(mode defs.ExitMode) {
+	data.GIsExiting.Store(true) // 已建立的连接上还可能收到请求，由middlewares.Exit返回503
+	if mode != defs.ExitModeSaveAndExit {
+		data.Log().Info().Int32("mode", int32(mode)).Int64("inflight", data.GInflight.Load()).Msg("main progress shutdown now")
+		_ = httpServer.Close()
+		return
+	}
+
+	wait := time.Second * time.Duration(data.Config().ShutdownWait)
+	data.Log().Info().Int32("mode", int32(mode)).Int64("inflight", data.GInflight.Load()).Msgf("main progress shutdown, wait at most %s", wait)
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	start := time.Now()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		data.Log().Warn().Err(err).Int64("inflight", data.GInflight.Load()).Msg("http server shutdown timeout, close connections")
+		_ = httpServer.Close()
+		return
 	}
-	initConst(pidFileInput)
+	data.Log().Info().Dur("cost", time.Since(start)).Msg("http server shutdown")
+}
+
+// Bootstrap 需要先调用AcquirePid
+func Bootstrap(r *gin.Engine) {
+	logPid()
 
 	// 进程退出前,保证数据都已落地
 	defer func() {
@@ -93,16 +146,17 @@ func Bootstrap(pidFileInput string, r *gin.Engine) {
 
 	wgBootstrap.Add(2)
 
+	httpServer = &http.Server{Addr: data.Config().Host, Handler: r}
 	go func() { // 开启http服务器
-		data.Log().Info().Msgf("http server listen on addr: %s", data.GConfig.Host)
-		if !data.GConfig.Debug {
+		data.Log().Info().Msgf("http server listen on addr: %s", data.Config().Host)
+		if !data.Config().Debug {
 			gin.SetMode(gin.ReleaseMode)
 		}
 		wgBootstrap.Done()
-		if err := r.Run(data.GConfig.Host); err != nil { // http server 停止后关闭整个进程
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) { // http server 异常停止后关闭整个进程
 			data.Log().Error().Err(err).Msg("http server stopped")
 			data.GExitMode.Store(int32(defs.ExitModePanicNoWait))
-			close(data.GSink.SinkQ)
+			data.GSink.Close()
 		}
 	}()
 
@@ -112,6 +166,11 @@ func Bootstrap(pidFileInput string, r *gin.Engine) {
 		wgBootstrap.Done()
 		for {
 			s := <-data.GSignalSys
+			if s == syscall.SIGHUP { // 交给主线程重新加载配置
+				data.Log().Info().Str("signal", s.String()).Msg("recv reload signal")
+				data.GSink.SendSinkQ(defs.EssReload, "signal")
+				continue
+			}
 			if s == syscall.SIGINT || s == syscall.SIGTERM || s == syscall.SIGKILL {
 				data.Log().Info().Str("signal", s.String()).Msg("recv system exit signal")
 				if s == syscall.SIGKILL {
@@ -119,19 +178,15 @@ func Bootstrap(pidFileInput string, r *gin.Engine) {
 				} else {
 					data.GExitMode.Store(int32(defs.ExitModeSaveAndExit))
 				}
-				close(data.GSink.SinkQ)
+				data.GSink.Close()
 				break
 			}
 		}
 	}()
 
 	wgBootstrap.Wait()
-	if err := savePid(); err != nil {
-		data.Log().Error().Err(err).Msg("bootstrap save pid failed")
-		panic(err)
-	}
 
-	data.Log().Info().Bool("IsDebug", data.GConfig.Debug).Msg("bootstrap success...")
+	data.Log().Info().Bool("IsDebug", data.Config().Debug).Msg("bootstrap success...")
 	logger.Flush() // 立即把启动日志写入文件，便于观察是否启动成功
 
 	ticker := time.NewTicker(time.Millisecond * 20)
@@ -141,18 +196,11 @@ Loop:
 		select {
 		case <-ticker.C: // 定时器驱动
 			update()
-		case pak, ok := <-data.GSink.SinkQ: // 事件驱动
+		case ev, ok := <-data.GSink.SinkQ: // 事件驱动
 			if ok {
-				data.GSink.ConsumePak(pak)
-			} else { // false对应close(data.GSink.SinkQ)通道关闭
-				mode := data.GExitMode.Load()
-				if mode == int32(defs.ExitModeSaveAndExit) {
-					data.GIsExiting.Store(true)
-					data.Log().Info().Int32("mode", mode).Msgf("main progress shutdown after %d seconds", data.GConfig.ShutdownWait)
-					time.Sleep(time.Second * time.Duration(data.GConfig.ShutdownWait)) // 等待已经接收到的http请求全部完成[如果已经触发的goroutine有耗时操作的话]
-				} else {
-					data.Log().Info().Int32("mode", mode).Msgf("main progress shutdown now")
-				}
+				data.GSink.ConsumePak(ev)
+			} else { // false对应data.GSink.Close()通道关闭，关闭前已进入队列的事件都已处理
+				shutdownHttp(defs.ExitMode(data.GExitMode.Load()))
 				break Loop
 			}
 		}
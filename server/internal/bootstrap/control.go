@@ -0,0 +1,77 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"simpletools/internal/data"
+	"simpletools/internal/pidfile"
+	"syscall"
+	"time"
+)
+
+const (
+	controlStop   = "stop"
+	controlStatus = "status"
+	controlReload = "reload"
+
+	stopExtraWait       = 10 * time.Second // 在shutdown_wait和任务drain_timeout之外多等待的时间
+	statusNotRunning    = 3                // 与LSB init脚本的status约定一致
+	stopCheckInterval   = 100 * time.Millisecond
+	defaultDrainTimeout = 30
+)
+
+// Control 向pid文件记录的进程发送控制命令 stop/status/reload，返回进程退出码；不是控制命令时ok为false
+func Control(cmd, useConfig string) (code int, ok bool) {
+	if cmd != controlStop && cmd != controlStatus && cmd != controlReload {
+		return 0, false
+	}
+	cfg, err := data.LoadConfig(useConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config %s failed: %v\n", useConfig, err)
+		return 1, true
+	}
+	path := cfg.PidFile
+	pid, err := pidfile.Read(path)
+	if errors.Is(err, pidfile.ErrNotRunning) {
+		fmt.Printf("not running, pid file:%s\n", path)
+		if cmd == controlStatus {
+			return statusNotRunning, true
+		}
+		return 1, true
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read pid file %s failed: %v\n", path, err)
+		return 1, true
+	}
+
+	switch cmd {
+	case controlStatus:
+		fmt.Printf("running in pid:%d\n", pid)
+	case controlReload:
+		if err = syscall.Kill(pid, syscall.SIGHUP); err != nil {
+			fmt.Fprintf(os.Stderr, "send reload signal to pid:%d failed: %v\n", pid, err)
+			return 1, true
+		}
+		fmt.Printf("reload signal sent to pid:%d\n", pid)
+	case controlStop:
+		if err = syscall.Kill(pid, syscall.SIGTERM); err != nil {
+			fmt.Fprintf(os.Stderr, "send stop signal to pid:%d failed: %v\n", pid, err)
+			return 1, true
+		}
+		drain := cfg.Jobs.DrainTimeout
+		if drain <= 0 {
+			drain = defaultDrainTimeout
+		}
+		timeout := time.Duration(cfg.ShutdownWait+drain)*time.Second + stopExtraWait
+		for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(stopCheckInterval) {
+			if !pidfile.Alive(pid) {
+				fmt.Printf("stopped pid:%d\n", pid)
+				return 0, true
+			}
+		}
+		fmt.Fprintf(os.Stderr, "pid:%d still running after %s\n", pid, timeout)
+		return 1, true
+	}
+	return 0, true
+}
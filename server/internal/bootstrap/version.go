@@ -0,0 +1,42 @@
+package bootstrap
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// 构建信息，编译时通过ldflags注入，例如：
+// go build -ldflags "-X simpletools/internal/bootstrap.Version=v1.2.0 -X simpletools/internal/bootstrap.Commit=abc1234 -X simpletools/internal/bootstrap.LastChangedDate=20241018120000"
+var (
+	Version         = "dev"
+	Commit          string
+	LastChangedDate string
+)
+
+func init() {
+	if Commit != "" && LastChangedDate != "" {
+		return
+	}
+	info, ok := debug.ReadBuildInfo() // 没有注入时使用go build自动记录的vcs信息
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value
+			if len(Commit) > 12 {
+				Commit = Commit[:12]
+			}
+		case s.Key == "vcs.time" && LastChangedDate == "":
+			LastChangedDate = s.Value
+		}
+	}
+}
+
+// BuildInfo 版本命令输出的构建信息
+func BuildInfo() string {
+	return fmt.Sprintf("Version: %s\nCommit: %s\nLast Changed Date: %s\nGo Version: %s %s/%s",
+		Version, Commit, LastChangedDate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
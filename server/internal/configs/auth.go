@@ -0,0 +1,10 @@
+package configs
+
+// AuthConfig 用户名密码登录，登录后签发短期的access token和可轮换的刷新token
+type AuthConfig struct {
+	UsersPath    string `json:"users_path"`    // 用户持久化文件，保存bcrypt哈希，为空时只保存在内存
+	SessionsPath string `json:"sessions_path"` // 退出时保存登录会话和吊销列表的文件，为空时重启后需要重新登录
+	TokenTTL     int    `json:"token_ttl"`     // access token有效秒数，默认900
+	RefreshTTL   int    `json:"refresh_ttl"`   // 刷新token有效秒数，每次刷新后重新计算，默认604800
+	RequireLogin bool   `json:"require_login"` // 大模型相关接口是否必须登录后访问
+}
@@ -1,12 +1,176 @@
 package configs
 
-import "simpletools/lib/logger"
+import (
+	"fmt"
+	"net"
+	"simpletools/lib/logger"
+	"strconv"
+	"strings"
+)
+
+const (
+	jwtKeyMinLen    = 16
+	maxShutdownWait = 600
+	maxSignSkew     = 3600
+)
 
 type ServerConfig struct {
-	Host         string        `json:"host"`          // 本机监听地址 IP:PORT
-	RemoteAddr   string        `json:"remote_addr"`   // 远端请求地址 URL
-	Platform     string        `json:"platform"`      // 平台
-	ShutdownWait int           `json:"shutdown_wait"` // 关闭等待时间
-	Debug        bool          `json:"debug"`         // 是否调试模式
-	Logger       logger.Config `json:"logger"`
+	Host           string            `json:"host"`            // 本机监听地址 IP:PORT
+	RemoteAddr     string            `json:"remote_addr"`     // 远端请求地址 URL
+	Remote         RemoteConfig      `json:"remote"`          // 远端请求的签名、加密和轮询配置
+	Platform       string            `json:"platform"`        // 平台
+	ShutdownWait   int               `json:"shutdown_wait"`   // 关闭等待时间
+	PidFile        string            `json:"pid_file"`        // pid文件路径
+	Debug          bool              `json:"debug"`           // 是否调试模式
+	JwtKey         string            `json:"jwt_key"`         // jwt签名密钥(HS256，kid为空)，支持env:/file:间接引用
+	Jwt            JwtConfig         `json:"jwt"`             // jwt的iss/aud和可轮换的密钥
+	Admins         []string          `json:"admins"`          // 管理员用户名，可访问/api/admin/接口
+	Auth           AuthConfig        `json:"auth"`            // 用户登录
+	RequestSign    RequestSignConfig `json:"request_sign"`    // 请求签名，X-Nonce/X-Timestamp与请求内容绑定
+	CorsOrigins    []string          `json:"cors_origins"`    // 允许跨域的来源，为空或包含*时不限制
+	TrustedProxies []string          `json:"trusted_proxies"` // 反向代理的IP或CIDR，只使用这些地址转发的X-Forwarded-For，为空时使用连接地址
+	ConfigWatch    int               `json:"config_watch"`    // 检查配置文件修改的间隔秒数，修改后自动重新加载，0不检查
+	GlossaryPath   string            `json:"glossary_path"`   // 术语表持久化文件，为空时只保存在内存
+	Jobs           JobConfig         `json:"jobs"`            // 异步任务
+	Logger         logger.Config     `json:"logger"`
+	LLM            LLMConfig         `json:"llm"` // 大模型提供方配置
+}
+
+// ResolveSecrets 把配置中的密钥引用替换为真实值，只在启动时调用一次
+func (c *ServerConfig) ResolveSecrets() error {
+	var err error
+	if c.JwtKey, err = ResolveSecret(c.JwtKey); err != nil {
+		return fmt.Errorf("jwt_key: %w", err)
+	}
+	for i := range c.Jwt.Keys {
+		kc := &c.Jwt.Keys[i]
+		if kc.Key, err = ResolveSecret(kc.Key); err != nil {
+			return fmt.Errorf("jwt.keys %s key: %w", kc.Kid, err)
+		}
+	}
+	if c.Remote.SignKey, err = ResolveSecret(c.Remote.SignKey); err != nil {
+		return fmt.Errorf("remote.sign_key: %w", err)
+	}
+	if c.Remote.EncryptKey, err = ResolveSecret(c.Remote.EncryptKey); err != nil {
+		return fmt.Errorf("remote.encrypt_key: %w", err)
+	}
+	for i := range c.RequestSign.Clients {
+		client := &c.RequestSign.Clients[i]
+		if client.Secret, err = ResolveSecret(client.Secret); err != nil {
+			return fmt.Errorf("request_sign client %s secret: %w", client.Id, err)
+		}
+	}
+	for i := range c.LLM.Providers {
+		pc := &c.LLM.Providers[i]
+		if pc.ApiKey, err = ResolveSecret(pc.ApiKey); err != nil {
+			return fmt.Errorf("llm provider %s api_key: %w", pc.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidationError 所有不合法的配置项，一次性提示全部问题
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e, "; ")
+}
+
+// Validate 检查必填项和取值范围，返回ValidationError汇总所有问题
+func (c *ServerConfig) Validate() error {
+	var errs ValidationError
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, port, err := net.SplitHostPort(c.Host)
+	n, _ := strconv.Atoi(port)
+	check(err == nil && n >= 0 && n <= 65535, "host %q must be IP:PORT", c.Host)
+	check(c.ShutdownWait > 0 && c.ShutdownWait <= maxShutdownWait, "shutdown_wait must be in [1, %d]", maxShutdownWait)
+	check(c.ConfigWatch >= 0, "config_watch must be >= 0")
+	check(c.PidFile != "", "pid_file cannot be empty")
+	_, err = c.Keyring()
+	check(err == nil, "%v", err)
+	check(c.Jwt.Issuer != "" && c.Jwt.Audience != "", "jwt.issuer and jwt.audience cannot be empty")
+	check(c.Auth.TokenTTL > 0, "auth.token_ttl must be > 0")
+	check(c.Auth.RefreshTTL >= c.Auth.TokenTTL, "auth.refresh_ttl must be >= auth.token_ttl")
+	check(c.RequestSign.Skew > 0 && c.RequestSign.Skew <= maxSignSkew, "request_sign.skew must be in [1, %d]", maxSignSkew)
+	check(c.RequestSign.MaxBody > 0, "request_sign.max_body must be > 0")
+	check(c.RequestSign.ClientNonceMax > 0 && c.RequestSign.ClientNonceMax <= c.RequestSign.NonceMax, "request_sign.client_nonce_max must be in [1, request_sign.nonce_max]")
+	check(!c.RequestSign.Required || len(c.RequestSign.Clients) > 0, "request_sign.clients cannot be empty when required")
+	clientIds := make(map[string]struct{}, len(c.RequestSign.Clients))
+	for _, client := range c.RequestSign.Clients {
+		_, dup := clientIds[client.Id]
+		clientIds[client.Id] = struct{}{}
+		check(client.Id != "" && !dup, "request_sign client id %q is empty or duplicated", client.Id)
+		check(len(client.Secret) >= jwtKeyMinLen, "request_sign client %s secret is shorter than %d", client.Id, jwtKeyMinLen)
+	}
+	for _, origin := range c.CorsOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"), "cors_origins %q must be * or start with http:// or https://", origin)
+	}
+	for _, proxy := range c.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "trusted_proxies %q must be an IP or CIDR", proxy)
+	}
+
+	check(c.Logger.LogPath != "", "logger.log_path is empty")
+	check(c.Logger.LogLevel >= -1 && c.Logger.LogLevel <= 7, "logger.log_level must be in [-1, 7]")
+	check(c.Logger.MaxSize >= 0 && c.Logger.HourRotate >= 0, "logger.max_size and logger.hour_rotate must be >= 0")
+	check(c.Logger.MaxSize > 0 || c.Logger.HourRotate > 0, "logger.max_size and logger.hour_rotate cannot be both 0")
+
+	check(c.Jobs.Workers >= 0 && c.Jobs.QueueSize >= 0 && c.Jobs.UserPending >= 0, "jobs.workers, jobs.queue_size and jobs.user_pending must be >= 0")
+	check(c.Jobs.Keep >= 0 && c.Jobs.DrainTimeout >= 0, "jobs.keep and jobs.drain_timeout must be >= 0")
+
+	if c.RemoteAddr != "" {
+		check(strings.HasPrefix(c.RemoteAddr, "http://") || strings.HasPrefix(c.RemoteAddr, "https://"), "remote_addr must start with http:// or https://")
+		check(c.Remote.SignKey != "", "remote.sign_key is missing")
+		n := len(c.Remote.EncryptKey)
+		check(n == 16 || n == 24 || n == 32, "remote.encrypt_key must be 16, 24 or 32 bytes")
+		check(c.Remote.Interval > 0 && c.Remote.Timeout > 0, "remote.interval and remote.timeout must be > 0")
+	}
+	c.LLM.validate(check)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Masked 隐藏密钥后的副本，用于输出当前生效的配置
+func (c *ServerConfig) Masked() *ServerConfig {
+	cp := *c
+	cp.JwtKey = mask(c.JwtKey)
+	cp.Jwt.Keys = make([]JwtKeyConfig, len(c.Jwt.Keys))
+	for i, kc := range c.Jwt.Keys {
+		kc.Key = mask(kc.Key)
+		cp.Jwt.Keys[i] = kc
+	}
+	cp.Remote.SignKey = mask(c.Remote.SignKey)
+	cp.Remote.EncryptKey = mask(c.Remote.EncryptKey)
+	cp.RequestSign.Clients = make([]SignClientConfig, len(c.RequestSign.Clients))
+	for i, client := range c.RequestSign.Clients {
+		client.Secret = mask(client.Secret)
+		cp.RequestSign.Clients[i] = client
+	}
+	cp.LLM.Providers = make([]LLMProviderConfig, len(c.LLM.Providers))
+	for i, pc := range c.LLM.Providers {
+		pc.ApiKey = mask(pc.ApiKey)
+		if len(pc.Headers) > 0 { // 自建网关的鉴权通常放在请求头中，全部隐藏
+			headers := make(map[string]string, len(pc.Headers))
+			for k, v := range pc.Headers {
+				headers[k] = mask(v)
+			}
+			pc.Headers = headers
+		}
+		cp.LLM.Providers[i] = pc
+	}
+	return &cp
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "******"
 }
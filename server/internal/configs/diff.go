@@ -0,0 +1,107 @@
+package configs
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable 运行中可以直接生效的配置项，其余配置项修改后需要重启
+var reloadable = []string{
+	"shutdown_wait",
+	"jwt_key",
+	"jwt",
+	"admins",
+	"auth.token_ttl",
+	"auth.refresh_ttl",
+	"auth.require_login",
+	"request_sign.required",
+	"request_sign.skew",
+	"request_sign.max_body",
+	"request_sign.clients",
+	"cors_origins",
+	"config_watch",
+	"logger.log_level",
+	"llm.providers",
+	"llm.default",
+	"llm.routes",
+	"llm.quota.daily_tokens",
+	"llm.quota.user_daily_tokens",
+	"llm.quota.keep_days",
+	"llm.quota.anonymous_max",
+	"llm.batch_workers",
+}
+
+// Reloadable 配置项是否可以热加载，field为Diff返回的路径
+func Reloadable(field string) bool {
+	for _, r := range reloadable {
+		if field == r || strings.HasPrefix(field, r+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge 在prev的副本上替换可以热加载的配置项，需要重启的配置项保留prev的值，使Config()与实际生效的配置一致
+func Merge(prev, cur *ServerConfig) *ServerConfig {
+	merged := *prev
+	mergeValue(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(*cur), "")
+	return &merged
+}
+
+// mergeValue 与diffValue相同按json标签逐层处理，可以热加载的配置项整体替换
+func mergeValue(dst, src reflect.Value, prefix string) {
+	if prefix != "" && Reloadable(prefix) {
+		dst.Set(src)
+		return
+	}
+	if dst.Kind() != reflect.Struct {
+		return
+	}
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		mergeValue(dst.Field(i), src.Field(i), name)
+	}
+}
+
+// Diff 比较两份配置，返回有变化的配置项路径，如 logger.log_level、llm.providers；只返回名称不返回值，避免密钥进入日志
+func Diff(prev, cur *ServerConfig) []string {
+	var fields []string
+	diffValue(reflect.ValueOf(*prev), reflect.ValueOf(*cur), "", &fields)
+	return fields
+}
+
+// diffValue 结构体按json标签逐层比较，其他类型整体比较
+func diffValue(a, b reflect.Value, prefix string, fields *[]string) {
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*fields = append(*fields, prefix)
+		}
+		return
+	}
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		diffValue(a.Field(i), b.Field(i), name, fields)
+	}
+}
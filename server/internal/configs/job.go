@@ -0,0 +1,10 @@
+package configs
+
+type JobConfig struct {
+	Workers      int    `json:"workers"`       // 同时执行的任务数，默认2
+	QueueSize    int    `json:"queue_size"`    // 等待执行的任务上限，默认100
+	UserPending  int    `json:"user_pending"`  // 单个用户未完成的任务上限，默认10
+	Keep         int    `json:"keep"`          // 任务结束后保留结果的秒数，默认3600
+	DrainTimeout int    `json:"drain_timeout"` // 正常退出时等待执行中任务完成的秒数，超时后取消并在下次启动时重新执行，默认30
+	PersistPath  string `json:"persist_path"`  // 正常退出时保存未完成任务和结果的文件，为空则不保存
+}
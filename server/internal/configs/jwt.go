@@ -0,0 +1,110 @@
+package configs
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// JwtConfig token的签发和校验，keys为空时只使用jwt_key(HS256，kid为空)
+type JwtConfig struct {
+	Issuer    string         `json:"issuer"`     // 签发和校验的iss，默认simpletools-admin
+	Audience  string         `json:"audience"`   // 签发和校验的aud，默认simpletools
+	ActiveKid string         `json:"active_kid"` // 签发新token使用的密钥，为空时使用jwt_key
+	Keys      []JwtKeyConfig `json:"keys"`       // 轮换时先加入新密钥并切换active_kid，旧token全部过期后再删除旧密钥
+}
+
+type JwtKeyConfig struct {
+	Kid       string `json:"kid"`
+	Alg       string `json:"alg"`        // HS256 或 EdDSA
+	Key       string `json:"key"`        // HS256的密钥或EdDSA的PKCS8私钥PEM，支持env:/file:间接引用；EdDSA只用于校验时可以为空
+	PublicKey string `json:"public_key"` // EdDSA的公钥PEM，为空时从私钥推导
+}
+
+// JwtKey 解析后的密钥，Sign为nil时只能用于校验
+type JwtKey struct {
+	Kid    string
+	Method jwt.SigningMethod
+	Sign   any
+	Verify any
+}
+
+// Keyring 按kid查找校验密钥，Active为签发新token使用的密钥
+type Keyring struct {
+	Active *JwtKey
+	Keys   map[string]*JwtKey
+}
+
+// Keyring 解析jwt_key和jwt.keys，需要在ResolveSecrets之后调用
+func (c *ServerConfig) Keyring() (*Keyring, error) {
+	ring := &Keyring{Keys: make(map[string]*JwtKey)}
+	if c.JwtKey != "" {
+		if len(c.JwtKey) < jwtKeyMinLen {
+			return nil, fmt.Errorf("jwt_key is shorter than %d", jwtKeyMinLen)
+		}
+		if Burned(c.JwtKey) {
+			return nil, fmt.Errorf("jwt_key is a leaked key, generate a new one")
+		}
+		ring.Keys[""] = &JwtKey{Method: jwt.SigningMethodHS256, Sign: []byte(c.JwtKey), Verify: []byte(c.JwtKey)}
+	}
+	for _, kc := range c.Jwt.Keys {
+		if kc.Kid == "" {
+			return nil, fmt.Errorf("jwt.keys kid is empty")
+		}
+		if _, ok := ring.Keys[kc.Kid]; ok {
+			return nil, fmt.Errorf("jwt.keys kid %s is duplicated", kc.Kid)
+		}
+		key, err := kc.parse()
+		if err != nil {
+			return nil, fmt.Errorf("jwt.keys %s: %w", kc.Kid, err)
+		}
+		ring.Keys[kc.Kid] = key
+	}
+	if len(ring.Keys) == 0 {
+		return nil, fmt.Errorf("jwt_key or jwt.keys is required")
+	}
+	ring.Active = ring.Keys[c.Jwt.ActiveKid]
+	if ring.Active == nil || ring.Active.Sign == nil {
+		return nil, fmt.Errorf("jwt.active_kid %q is not a key that can sign", c.Jwt.ActiveKid)
+	}
+	return ring, nil
+}
+
+func (kc JwtKeyConfig) parse() (*JwtKey, error) {
+	key := &JwtKey{Kid: kc.Kid}
+	switch kc.Alg {
+	case jwt.SigningMethodHS256.Alg():
+		if len(kc.Key) < jwtKeyMinLen {
+			return nil, fmt.Errorf("key is shorter than %d", jwtKeyMinLen)
+		}
+		if Burned(kc.Key) {
+			return nil, fmt.Errorf("key is a leaked key, generate a new one")
+		}
+		key.Method, key.Sign, key.Verify = jwt.SigningMethodHS256, []byte(kc.Key), []byte(kc.Key)
+	case jwt.SigningMethodEdDSA.Alg():
+		key.Method = jwt.SigningMethodEdDSA
+		if kc.Key != "" {
+			private, err := jwt.ParseEdPrivateKeyFromPEM([]byte(kc.Key))
+			if err != nil {
+				return nil, err
+			}
+			key.Sign, key.Verify = private, private.(ed25519.PrivateKey).Public()
+		}
+		if kc.PublicKey != "" {
+			public, err := jwt.ParseEdPublicKeyFromPEM([]byte(kc.PublicKey))
+			if err != nil {
+				return nil, err
+			}
+			if key.Verify != nil && !key.Verify.(ed25519.PublicKey).Equal(public) {
+				return nil, fmt.Errorf("public_key does not match key")
+			}
+			key.Verify = public
+		}
+		if key.Verify == nil {
+			return nil, fmt.Errorf("key or public_key is required")
+		}
+	default:
+		return nil, fmt.Errorf("alg must be HS256 or EdDSA")
+	}
+	return key, nil
+}
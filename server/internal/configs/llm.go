@@ -0,0 +1,76 @@
+package configs
+
+import (
+	"fmt"
+)
+
+const maxBatchWorkers = 64
+
+type LLMProviderConfig struct {
+	Name       string            `json:"name"`        // 提供方名称，唯一，路由中引用
+	Type       string            `json:"type"`        // 提供方类型 deepseek|openai
+	BaseURL    string            `json:"base_url"`    // 接口根地址，如 https://api.deepseek.com 或 http://gateway/v1
+	ApiKey     string            `json:"api_key"`     // 鉴权密钥，支持env:/file:间接引用
+	Model      string            `json:"model"`       // 默认模型
+	Headers    map[string]string `json:"headers"`     // 额外请求头，自建网关使用
+	Timeout    int               `json:"timeout"`     // 单次请求超时秒数(含重试和流式读取)，默认120
+	MaxRetries int               `json:"max_retries"` // 429/5xx重试次数，默认2，-1表示不重试
+
+	AllowAnonymous bool `json:"allow_anonymous"` // 自建网关无需鉴权时允许api_key为空
+}
+
+type LLMCacheConfig struct {
+	Enable      bool   `json:"enable"`       // 是否缓存相同请求的结果
+	TTL         int    `json:"ttl"`          // 缓存有效期，单位秒，默认86400
+	MaxMemory   int    `json:"max_memory"`   // 缓存最大内存，单位M，默认64
+	PersistPath string `json:"persist_path"` // 持久化文件路径，为空则重启后缓存失效
+}
+
+type LLMQuotaConfig struct {
+	DailyTokens     int64            `json:"daily_tokens"`      // 每个用户每日token额度，0不限制
+	UserDailyTokens map[string]int64 `json:"user_daily_tokens"` // 单独设置的额度，key为 用户名 或 用户名|平台
+	KeepDays        int              `json:"keep_days"`         // 用量统计保留天数，默认31
+	AnonymousMax    int              `json:"anonymous_max"`     // 每天单独统计的未登录来源数，超出后新的来源合并计入anonymous，默认10000
+	PersistPath     string           `json:"persist_path"`      // 退出时保存用量统计，为空则重启后额度重置
+}
+
+type LLMConfig struct {
+	Providers []LLMProviderConfig `json:"providers"` // 可用的提供方列表
+	Default   string              `json:"default"`   // 默认提供方名称，路由未配置时使用
+	Routes    map[string]string   `json:"routes"`    // 路由到提供方的映射，如 aitranslate -> deepseek
+	Cache     LLMCacheConfig      `json:"cache"`     // 结果缓存
+	Quota     LLMQuotaConfig      `json:"quota"`     // 用量额度
+
+	BatchWorkers int `json:"batch_workers"` // 批量和文档翻译时单个请求同时发给提供方的请求数，默认4
+}
+
+// validate 提供方、路由和各项取值范围，check不通过时记录错误
+func (c *LLMConfig) validate(check func(ok bool, format string, args ...any)) {
+	check(len(c.Providers) > 0, "llm.providers is empty")
+	names := make(map[string]bool, len(c.Providers))
+	for i, pc := range c.Providers {
+		name := pc.Name
+		if name == "" {
+			name = pc.Type
+		}
+		field := fmt.Sprintf("llm.providers[%d]", i)
+		check(name != "", "%s name and type are both empty", field)
+		check(!names[name], "%s name %s duplicated", field, name)
+		names[name] = true
+		check(pc.Type == "deepseek" || pc.Type == "openai", "%s type %q must be deepseek or openai", field, pc.Type)
+		if pc.Type == "openai" {
+			check(pc.BaseURL != "" && pc.Model != "", "%s base_url and model are required for openai", field)
+		}
+		check(pc.ApiKey != "" || pc.AllowAnonymous, "llm provider %s api_key is missing", name)
+		check(!Burned(pc.ApiKey), "llm provider %s api_key is a leaked key, rotate it", name)
+		check(pc.Timeout >= 0, "%s timeout must be >= 0", field)
+		check(pc.MaxRetries >= -1, "%s max_retries must be >= -1", field)
+	}
+	check(c.Default == "" || names[c.Default], "llm.default %s not found in providers", c.Default)
+	for route, name := range c.Routes {
+		check(names[name], "llm.routes %s use unknown provider:%s", route, name)
+	}
+	check(c.Cache.TTL >= 0 && c.Cache.MaxMemory >= 0, "llm.cache.ttl and llm.cache.max_memory must be >= 0")
+	check(c.Quota.DailyTokens >= 0 && c.Quota.KeepDays >= 0 && c.Quota.AnonymousMax >= 0, "llm.quota.daily_tokens, llm.quota.keep_days and llm.quota.anonymous_max must be >= 0")
+	check(c.BatchWorkers >= 0 && c.BatchWorkers <= maxBatchWorkers, "llm.batch_workers must be in [0, %d]", maxBatchWorkers)
+}
@@ -0,0 +1,169 @@
+package configs
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"simpletools/lib/logger"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvPrefix 环境变量覆盖配置项的前缀，配置路径按json名称用_连接后转大写，如 SIMPLETOOLS_LLM_QUOTA_DAILY_TOKENS
+	EnvPrefix = "SIMPLETOOLS_"
+	// SecretEnvPrefix env:引用的密钥使用该前缀命名，不作为配置项覆盖，避免绕过env:引用直接覆盖配置
+	SecretEnvPrefix = EnvPrefix + "SECRET_"
+)
+
+// Default 内置默认值，配置文件、环境变量、命令行参数依次覆盖
+func Default() *ServerConfig {
+	return &ServerConfig{
+		Host:         "0.0.0.0:1235",
+		ShutdownWait: 10,
+		PidFile:      "simpletools.pid",
+		Logger:       logger.Config{LogPath: "./output/", MaxSize: 20},
+		Remote:       RemoteConfig{Interval: 60, Timeout: 10},
+		Jwt:          JwtConfig{Issuer: "simpletools-admin", Audience: "simpletools"},
+		Auth:         AuthConfig{TokenTTL: 900, RefreshTTL: 604800},
+		RequestSign:  RequestSignConfig{Skew: 300, MaxBody: 32, NonceMax: 1000000, ClientNonceMax: 200000},
+		Jobs: JobConfig{
+			Workers:      2,
+			QueueSize:    100,
+			UserPending:  10,
+			Keep:         3600,
+			DrainTimeout: 30,
+		},
+		LLM: LLMConfig{
+			Cache:        LLMCacheConfig{TTL: 86400, MaxMemory: 64},
+			Quota:        LLMQuotaConfig{KeepDays: 31, AnonymousMax: 10000},
+			BatchWorkers: 4,
+		},
+	}
+}
+
+// Load 在默认值上依次合并配置文件和环境变量，按扩展名支持json/yaml/toml，不解析密钥也不校验
+func Load(path string, environ []string) (*ServerConfig, error) {
+	cfg := Default()
+	if err := loadFile(cfg, path); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := loadEnv(cfg, environ); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile yaml和toml先转成json，统一使用json标签，文件中没有写的配置项保留默认值
+func loadFile(cfg *ServerConfig, path string) error {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return jsoniter.Unmarshal(bs, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(bs, &tree)
+	case ".toml":
+		err = toml.Unmarshal(bs, &tree)
+	default:
+		return fmt.Errorf("unsupported config format:%s", ext)
+	}
+	if err != nil {
+		return err
+	}
+	if bs, err = jsoniter.Marshal(tree); err != nil {
+		return err
+	}
+	return jsoniter.Unmarshal(bs, cfg)
+}
+
+// loadEnv 字符串、数字、布尔直接转换，字符串数组用逗号分隔，其他类型(如llm.providers)使用json
+func loadEnv(cfg *ServerConfig, environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix) && !strings.HasPrefix(k, SecretEnvPrefix) {
+			env[k] = v
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	var errs ValidationError
+	walkFields(reflect.ValueOf(cfg).Elem(), "", func(path string, v reflect.Value) {
+		key := EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+		s, ok := env[key]
+		if !ok {
+			return
+		}
+		if err := setValue(v, s); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+		}
+	})
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// walkFields 按json名称遍历结构体中的叶子配置项，path为点号连接的路径
+func walkFields(v reflect.Value, prefix string, fn func(path string, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if fv := v.Field(i); fv.Kind() == reflect.Struct {
+			walkFields(fv, name, fn)
+		} else {
+			fn(name, fv)
+		}
+	}
+}
+
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String || strings.HasPrefix(strings.TrimSpace(s), "[") {
+			return jsoniter.UnmarshalFromString(s, v.Addr().Interface())
+		}
+		list := make([]string, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return jsoniter.UnmarshalFromString(s, v.Addr().Interface())
+	}
+	return nil
+}
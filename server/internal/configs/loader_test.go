@@ -0,0 +1,211 @@
+package configs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testJwtKey = "test-jwt-key-0123456789"
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeConfig(t, "server.json", `{
+  "host": "127.0.0.1:8000",
+  "jwt_key": "env:SIMPLETOOLS_SECRET_JWT_KEY",
+  "jobs": {"workers": 4},
+  "llm": {"quota": {"daily_tokens": 100}}
+}`)
+	environ := []string{
+		"SIMPLETOOLS_JOBS_WORKERS=8",
+		"SIMPLETOOLS_DEBUG=true",
+		"SIMPLETOOLS_CORS_ORIGINS=https://a.com, https://b.com,",
+		`SIMPLETOOLS_LLM_PROVIDERS=[{"name":"p","type":"openai","api_key":"env:SIMPLETOOLS_SECRET_P"}]`,
+		"SIMPLETOOLS_SECRET_JWT_KEY=" + testJwtKey, // 只作为env:引用的值，不覆盖配置项
+		"SIMPLETOOLS_SECRET_JOBS_WORKERS=99",
+		"SIMPLETOOLS_JWT_KEY_EXTRA=ignored", // 不对应任何配置项
+		"OTHER_HOST=ignored",
+	}
+	cfg, err := Load(path, environ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	def := Default()
+	cases := []struct {
+		name      string
+		got, want any
+	}{
+		{"file over default", cfg.Host, "127.0.0.1:8000"},
+		{"env over file", cfg.Jobs.Workers, 8},
+		{"default kept", cfg.Jobs.QueueSize, def.Jobs.QueueSize},
+		{"nested default kept", cfg.LLM.Quota.KeepDays, def.LLM.Quota.KeepDays},
+		{"file nested", cfg.LLM.Quota.DailyTokens, int64(100)},
+		{"env bool", cfg.Debug, true},
+		{"env string list", cfg.CorsOrigins, []string{"https://a.com", "https://b.com"}},
+		{"env json", len(cfg.LLM.Providers), 1},
+		{"secret not resolved", cfg.JwtKey, "env:SIMPLETOOLS_SECRET_JWT_KEY"},
+	}
+	for _, tc := range cases {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"server.yaml": "host: 127.0.0.1:8000\njobs:\n  workers: 4\ncors_origins:\n  - https://a.com\n",
+		"server.yml":  "host: 127.0.0.1:8000\njobs:\n  workers: 4\ncors_origins: [https://a.com]\n",
+		"server.toml": "host = \"127.0.0.1:8000\"\ncors_origins = [\"https://a.com\"]\n[jobs]\nworkers = 4\n",
+		"server.JSON": `{"host":"127.0.0.1:8000","jobs":{"workers":4},"cors_origins":["https://a.com"]}`,
+	}
+	for name, content := range files {
+		cfg, err := Load(writeConfig(t, name, content), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.Host != "127.0.0.1:8000" || cfg.Jobs.Workers != 4 || cfg.Jobs.QueueSize != Default().Jobs.QueueSize || len(cfg.CorsOrigins) != 1 {
+			t.Fatalf("%s: loaded %+v", name, cfg)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+		environ []string
+		errs    []string // 错误信息需要包含的内容
+	}{
+		{"missing file", "", "", nil, []string{"no such file"}},
+		{"unsupported format", "server.ini", "host=x", nil, []string{"unsupported config format:.ini"}},
+		{"bad json", "server.json", `{"host":`, nil, []string{"server.json"}},
+		{"bad yaml", "server.yaml", "host: [", nil, []string{"server.yaml"}},
+		{"bad env values aggregated", "server.json", `{}`,
+			[]string{"SIMPLETOOLS_JOBS_WORKERS=many", "SIMPLETOOLS_DEBUG=maybe", "SIMPLETOOLS_LLM_PROVIDERS={"},
+			[]string{"SIMPLETOOLS_JOBS_WORKERS", "SIMPLETOOLS_DEBUG", "SIMPLETOOLS_LLM_PROVIDERS"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "absent.json")
+			if tc.file != "" {
+				path = writeConfig(t, tc.file, tc.content)
+			}
+			_, err := Load(path, tc.environ)
+			if err == nil {
+				t.Fatal("load succeeded")
+			}
+			for _, s := range tc.errs {
+				if !strings.Contains(err.Error(), s) {
+					t.Fatalf("err %q does not contain %q", err, s)
+				}
+			}
+		})
+	}
+}
+
+func validConfig() *ServerConfig {
+	cfg := Default()
+	cfg.JwtKey = testJwtKey
+	cfg.LLM.Providers = []LLMProviderConfig{{Name: "deepseek", Type: "deepseek", ApiKey: "sk-test"}}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("default config with jwt_key: %v", err)
+	}
+
+	cfg := validConfig()
+	cfg.Host = "localhost"
+	cfg.ShutdownWait = 0
+	cfg.PidFile = ""
+	cfg.JwtKey = "short"
+	cfg.Auth.RefreshTTL = cfg.Auth.TokenTTL - 1
+	cfg.CorsOrigins = []string{"a.com"}
+	cfg.TrustedProxies = []string{"10.0.0.0/40"}
+	cfg.RemoteAddr = "ftp://remote"
+	cfg.LLM.Default = "missing"
+	cfg.LLM.Quota.AnonymousMax = -1
+	err := cfg.Validate()
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err %v is not ValidationError", err)
+	}
+	// 所有问题一次性返回
+	want := []string{"host", "shutdown_wait", "pid_file", "jwt_key", "auth.refresh_ttl", "cors_origins", "trusted_proxies",
+		"remote_addr", "remote.sign_key", "remote.encrypt_key", "llm.default", "anonymous_max"}
+	if len(verr) != len(want) {
+		t.Fatalf("%d errors, want %d: %v", len(verr), len(want), verr)
+	}
+	for i, field := range want {
+		if !strings.Contains(verr[i], field) {
+			t.Fatalf("error %d %q, want %s", i, verr[i], field)
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "invalid config: ") || strings.Count(err.Error(), "; ") != len(want)-1 {
+		t.Fatalf("error message %q", err)
+	}
+}
+
+func TestMasked(t *testing.T) {
+	cfg := validConfig()
+	cfg.Jwt.Keys = []JwtKeyConfig{{Kid: "k1", Key: "jwt-keys-secret-0123"}}
+	cfg.Remote.SignKey = "remote-sign"
+	cfg.Remote.EncryptKey = "0123456789abcdef"
+	cfg.RequestSign.Clients = []SignClientConfig{{Id: "web", Secret: "client-secret-0123"}}
+	cfg.LLM.Providers = []LLMProviderConfig{
+		{Name: "p", ApiKey: "sk-provider", Headers: map[string]string{"X-Gateway-Key": "gw"}},
+		{Name: "local"},
+	}
+
+	masked := cfg.Masked()
+	secrets := []string{masked.JwtKey, masked.Jwt.Keys[0].Key, masked.Remote.SignKey, masked.Remote.EncryptKey,
+		masked.RequestSign.Clients[0].Secret, masked.LLM.Providers[0].ApiKey, masked.LLM.Providers[0].Headers["X-Gateway-Key"]}
+	for i, s := range secrets {
+		if s != "******" {
+			t.Fatalf("secret %d not masked: %q", i, s)
+		}
+	}
+	if masked.LLM.Providers[1].ApiKey != "" || masked.Jwt.Keys[0].Kid != "k1" || masked.RequestSign.Clients[0].Id != "web" || masked.Host != cfg.Host {
+		t.Fatalf("non-secret fields changed: %+v", masked)
+	}
+
+	// 原配置不受影响
+	if cfg.JwtKey != testJwtKey || cfg.Jwt.Keys[0].Key != "jwt-keys-secret-0123" || cfg.RequestSign.Clients[0].Secret != "client-secret-0123" ||
+		cfg.LLM.Providers[0].ApiKey != "sk-provider" || cfg.LLM.Providers[0].Headers["X-Gateway-Key"] != "gw" {
+		t.Fatalf("masking modified the original config: %+v", cfg)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("SIMPLETOOLS_SECRET_JWT_KEY", " "+testJwtKey+"\n")
+	keyFile := writeConfig(t, "api_key", "sk-from-file\n")
+	cfg := Default()
+	cfg.JwtKey = "env:SIMPLETOOLS_SECRET_JWT_KEY"
+	cfg.LLM.Providers = []LLMProviderConfig{{Name: "p", ApiKey: "file:" + keyFile}, {Name: "q", ApiKey: "plain"}}
+	if err := cfg.ResolveSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JwtKey != testJwtKey || cfg.LLM.Providers[0].ApiKey != "sk-from-file" || cfg.LLM.Providers[1].ApiKey != "plain" {
+		t.Fatalf("resolved %q %q %q", cfg.JwtKey, cfg.LLM.Providers[0].ApiKey, cfg.LLM.Providers[1].ApiKey)
+	}
+
+	cfg.JwtKey = "env:SIMPLETOOLS_SECRET_MISSING"
+	if err := cfg.ResolveSecrets(); err == nil || !strings.Contains(err.Error(), "jwt_key") {
+		t.Fatalf("missing env err %v", err)
+	}
+}
@@ -4,6 +4,16 @@ import (
 	"simpletools/internal/defs"
 )
 
+// RemoteConfig 远端配置和数据接口，remote_addr为空时不启用
+type RemoteConfig struct {
+	SignKey    string `json:"sign_key"`    // 校验CommonResp.Sign的HMAC-SHA256密钥，支持env:/file:间接引用
+	EncryptKey string `json:"encrypt_key"` // 解密MysqlCommonResp.Encrypt的AES-GCM密钥，16/24/32字节，支持env:/file:间接引用
+	Interval   int    `json:"interval"`    // 轮询间隔秒数，默认60
+	Timeout    int    `json:"timeout"`     // 单次请求超时秒数，默认10
+	CachePath  string `json:"cache_path"`  // 最近一次校验通过的原始响应，远端不可用时使用，为空则不保存
+}
+
+// CommonResp 远端接口的统一响应，Sign为Data的签名
 type CommonResp struct {
 	Code defs.ErrCode `json:"code"`
 	Data string       `json:"data"`
@@ -0,0 +1,48 @@
+package configs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	secretPrefixEnv  = "env:"
+	secretPrefixFile = "file:"
+)
+
+// burnedSecrets 曾经硬编码并提交到仓库历史中的密钥(sha256)，任何人都能读到，不能再使用
+var burnedSecrets = map[string]bool{
+	"302166652c38570f73f206103e2c62e81ca4cf6a63e38aea7fe5b8c044392af3": true, // 旧的jwt签名密钥，用它签发的token可以被伪造
+	"fc4a1c30f4cd170c49932a77d1a8dc5e332593e61a0b34aa3a1600c765c60942": true, // 旧的deepseek api_key
+}
+
+// Burned 是否为已经泄露的旧密钥
+func Burned(secret string) bool {
+	sum := sha256.Sum256([]byte(secret))
+	return burnedSecrets[hex.EncodeToString(sum[:])]
+}
+
+// ResolveSecret 解析密钥配置，支持三种写法：
+// env:NAME 从环境变量读取；file:/run/secrets/xxx 从文件读取并去掉首尾空白；其他内容原样返回
+func ResolveSecret(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, secretPrefixEnv):
+		name := strings.TrimPrefix(v, secretPrefixEnv)
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret env %s not set", name)
+		}
+		return strings.TrimSpace(val), nil
+	case strings.HasPrefix(v, secretPrefixFile):
+		path := strings.TrimPrefix(v, secretPrefixFile)
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file read failed: %w", err)
+		}
+		return strings.TrimSpace(string(bs)), nil
+	}
+	return v, nil
+}
@@ -0,0 +1,27 @@
+package configs
+
+// RequestSignConfig 请求签名，算法见lib/reqsign
+type RequestSignConfig struct {
+	Required bool               `json:"required"` // 是否所有请求都必须签名，关闭时只校验带有X-Signature的请求
+	Skew     int                `json:"skew"`     // 允许X-Timestamp与服务器时间相差的秒数，过旧或超前都拒绝，默认300
+	MaxBody  int                `json:"max_body"` // 签名校验读取的请求体上限MB，默认32
+	Clients  []SignClientConfig `json:"clients"`
+
+	NonceMax       int `json:"nonce_max"`        // 时间窗口内记录的nonce总数上限，超过后拒绝请求，默认1000000，修改后需要重启
+	ClientNonceMax int `json:"client_nonce_max"` // 单个客户端(未签名的请求合计为一个)的nonce上限，默认200000，修改后需要重启
+}
+
+type SignClientConfig struct {
+	Id     string `json:"id"`     // 请求头X-Client-Id
+	Secret string `json:"secret"` // 签名密钥，支持env:/file:间接引用
+}
+
+// Secret 客户端的签名密钥，客户端不存在时返回false
+func (c *RequestSignConfig) Secret(id string) (string, bool) {
+	for _, client := range c.Clients {
+		if client.Id == id {
+			return client.Secret, true
+		}
+	}
+	return "", false
+}
@@ -1,56 +1,157 @@
 package data
 
 import (
-	jsoniter "github.com/json-iterator/go"
+	"fmt"
 	"os"
 	"path/filepath"
 	"simpletools/internal/configs"
+	"simpletools/internal/defs"
+	"simpletools/internal/jobs"
+	"simpletools/internal/llm"
+	"simpletools/internal/nonce"
+	"simpletools/internal/remote"
 	"simpletools/internal/sink"
+	"simpletools/internal/translate"
+	"simpletools/internal/users"
 	"simpletools/lib/logger"
 	"simpletools/lib/poller"
 	"sync/atomic"
 )
 
 var (
-	GLog            *logger.CustomLogger  // 线程安全的全局logger
-	GConfig         *configs.ServerConfig // 启动配置
-	GSignalSys      chan os.Signal        // 系统信号
-	GSink           *sink.EventSink       // 主线程信号接收器
-	GExitMode       atomic.Int32          // 进程退出模式
-	GIsExiting      atomic.Bool           // 服务器是否正在退出
-	GPollerLogFlush *poller.TimePoller    // 日志Flush管理
-	GTimeOffsetTs   atomic.Int64          // 游戏逻辑时间偏移量
-	GUser           *OnlineUserMgr        // 登录用户管理
+	GLog            *logger.CustomLogger                 // 线程安全的全局logger
+	GConfig         atomic.Pointer[configs.ServerConfig] // 当前配置，热加载时整体替换，通过Config()读取
+	GSignalSys      chan os.Signal                       // 系统信号
+	GSink           *sink.EventSink                      // 主线程信号接收器
+	GExitMode       atomic.Int32                         // 进程退出模式
+	GIsExiting      atomic.Bool                          // 服务器是否正在退出
+	GInflight       atomic.Int64                         // 正在处理的http请求数
+	GPollerLogFlush *poller.TimePoller                   // 日志Flush管理
+	GTimeOffsetTs   atomic.Int64                         // 游戏逻辑时间偏移量
+	GUser           *OnlineUserMgr                       // 登录会话管理
+	GRevoked        *RevokeList                          // 已吊销的access token
+	GPollerSession  *poller.TimePoller                   // 过期会话和吊销记录清理
+	GNonces         nonce.Store                          // 请求nonce，防止重放
+	GPollerNonce    *poller.TimePoller                   // 过期nonce清理
+	GUsers          users.Store                          // 用户名密码存储
+	GLLM            *llm.Manager                         // 大模型提供方管理
+	GUsage          *UsageMgr                            // 大模型用量统计
+	GGlossary       *translate.GlossaryStore             // 翻译术语表
+	GJobs           *jobs.Manager                        // 异步任务
+	GPollerJobClean *poller.TimePoller                   // 过期任务清理
+	GPollerConfig   *poller.TimePoller                   // 配置文件修改检查
+	GRemote         *remote.Client                       // 远端数据，未配置remote_addr时为nil
+	GPollerRemote   *poller.TimePoller                   // 远端数据轮询
 )
 
-func InitGlobal(useConfig string) error {
+// ConfigPath 配置文件的实际路径，useConfig只有文件名时从工作目录的etc下读取，否则按路径读取
+func ConfigPath(useConfig string) (string, error) {
+	if filepath.Base(useConfig) != useConfig {
+		return useConfig, nil
+	}
 	pathWork, err := os.Getwd()
 	if err != nil {
-		return err
+		return "", err
 	}
-	jsonStr, err := os.ReadFile(filepath.Join(pathWork, "./etc/"+useConfig))
+	return filepath.Join(pathWork, "./etc/"+useConfig), nil
+}
+
+// LoadConfig 合并默认值、配置文件和SIMPLETOOLS_环境变量，不解析密钥也不初始化其他模块，供命令行子命令使用
+func LoadConfig(useConfig string) (*configs.ServerConfig, error) {
+	path, err := ConfigPath(useConfig)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return configs.Load(path, os.Environ())
+}
+
+// ReadConfig 读取配置并应用命令行覆盖项，解析密钥后校验，override可以为nil
+// 优先级从低到高：内置默认值、配置文件、环境变量、命令行参数
+func ReadConfig(useConfig string, override func(*configs.ServerConfig)) (*configs.ServerConfig, error) {
+	scfg, err := LoadConfig(useConfig)
+	if err != nil {
+		return nil, err
 	}
-	scfg := &configs.ServerConfig{}
-	if err = jsoniter.Unmarshal(jsonStr, &scfg); err != nil {
+	if override != nil {
+		override(scfg)
+	}
+	if err = scfg.ResolveSecrets(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", useConfig, err)
+	}
+	if err = scfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", useConfig, err)
+	}
+	return scfg, nil
+}
+
+func InitGlobal(useConfig string, override func(*configs.ServerConfig)) error {
+	scfg, err := ReadConfig(useConfig, override)
+	if err != nil {
 		return err
 	}
-	GConfig = scfg
+	GConfig.Store(scfg)
+	initReload(useConfig, override)
 
-	GLog = logger.NewCustomLogger(GConfig.Logger, GConfig.Debug)
+	GLog = logger.NewCustomLogger(scfg.Logger, scfg.Debug)
 	Log().Info().Msg("bootstrap start...")
 
 	now := Seconds()
 	GPollerLogFlush = poller.NewTimePoller(now)
+	GPollerConfig = poller.NewTimePoller(now)
 
+	GNonces = nonce.NewMemoryStore(scfg.RequestSign.NonceMax, scfg.RequestSign.ClientNonceMax)
+	GPollerNonce = poller.NewTimePoller(now)
 	GUser = NewOnlineUserMgr()
+	GRevoked = NewRevokeList()
+	GPollerSession = poller.NewTimePoller(now)
+	if err = loadSessions(scfg.Auth.SessionsPath); err != nil {
+		return err
+	}
+	if GUsers, err = users.NewFileStore(scfg.Auth.UsersPath); err != nil {
+		return err
+	}
+
+	if GLLM, err = llm.NewManager(scfg.LLM); err != nil {
+		return err
+	}
+	GUsage = NewUsageMgr(scfg.LLM.Quota)
+	if err = loadUsage(scfg.LLM.Quota.PersistPath); err != nil {
+		return err
+	}
+	if GGlossary, err = translate.NewGlossaryStore(scfg.GlossaryPath); err != nil {
+		return err
+	}
+
+	if scfg.RemoteAddr != "" {
+		GRemote = remote.NewClient(scfg.RemoteAddr, scfg.Remote, GLog)
+		GPollerRemote = poller.NewTimePoller(0) // 启动后立即请求一次
+	}
 
 	GSignalSys = make(chan os.Signal, 1)
-	GSink = &sink.EventSink{SinkQ: make(chan interface{}, 40000)}
+	GJobs = jobs.NewManager(scfg.Jobs, GLog)
+	GPollerJobClean = poller.NewTimePoller(now)
+	GSink = sink.NewEventSink(40000, GLog)
+	GSink.On(defs.EssJob, func(ev sink.Event) {
+		GJobs.Dispatch(ev.Payload.(*jobs.Job))
+	})
+	GSink.On(defs.EssReload, func(ev sink.Event) {
+		_ = ReloadConfig(ev.Payload.(string))
+	})
+	queued, err := GJobs.Load()
+	if err != nil {
+		return err
+	}
+	for _, job := range queued { // 上次退出时未完成的任务重新排队
+		GSink.SendSinkQ(defs.EssJob, job)
+	}
 	return nil
 }
 
 func Log() *logger.CustomLogger {
 	return GLog
 }
+
+// Config 当前配置，返回的对象不会被修改，同一个请求内需要多次读取时先保存到局部变量
+func Config() *configs.ServerConfig {
+	return GConfig.Load()
+}
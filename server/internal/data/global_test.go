@@ -0,0 +1,82 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"simpletools/internal/configs"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+  "host": "127.0.0.1:8000",
+  "shutdown_wait": 5,
+  "jwt_key": "env:SIMPLETOOLS_SECRET_JWT_KEY",
+  "jobs": {"workers": 4, "queue_size": 50},
+  "llm": {"providers": [{"name": "deepseek", "type": "deepseek", "api_key": "env:SIMPLETOOLS_SECRET_DEEPSEEK"}]}
+}`
+
+// TestReadConfigLayers 优先级：默认值 < 配置文件 < 环境变量(不含SIMPLETOOLS_SECRET_) < 命令行参数
+func TestReadConfigLayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIMPLETOOLS_SECRET_JWT_KEY", "test-jwt-key-0123456789")
+	t.Setenv("SIMPLETOOLS_SECRET_DEEPSEEK", "sk-test")
+	t.Setenv("SIMPLETOOLS_SECRET_SHUTDOWN_WAIT", "99") // 密钥变量不作为配置项覆盖
+	t.Setenv("SIMPLETOOLS_HOST", "127.0.0.1:9000")
+	t.Setenv("SIMPLETOOLS_JOBS_WORKERS", "8")
+	t.Setenv("SIMPLETOOLS_LOGGER_LOG_LEVEL", "2")
+
+	override := func(cfg *configs.ServerConfig) { // 模拟 --host
+		cfg.Host = "127.0.0.1:9100"
+	}
+	cfg, err := ReadConfig(path, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := configs.Default()
+	cases := []struct {
+		name      string
+		got, want any
+	}{
+		{"flag over env", cfg.Host, "127.0.0.1:9100"},
+		{"env over file", cfg.Jobs.Workers, 8},
+		{"env over default", cfg.Logger.LogLevel, 2},
+		{"file over default", cfg.Jobs.QueueSize, 50},
+		{"secret env not an override", cfg.ShutdownWait, 5},
+		{"default", cfg.Jobs.UserPending, def.Jobs.UserPending},
+		{"secret resolved", cfg.JwtKey, "test-jwt-key-0123456789"},
+		{"provider secret resolved", cfg.LLM.Providers[0].ApiKey, "sk-test"},
+	}
+	for _, tc := range cases {
+		if tc.got != tc.want {
+			t.Fatalf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+
+	// 没有命令行参数时使用环境变量
+	if cfg, err = ReadConfig(path, nil); err != nil || cfg.Host != "127.0.0.1:9000" {
+		t.Fatalf("host %v err %v", cfg, err)
+	}
+
+	// 环境变量覆盖后的值同样需要校验，所有问题一起返回
+	t.Setenv("SIMPLETOOLS_SHUTDOWN_WAIT", "0")
+	t.Setenv("SIMPLETOOLS_JOBS_QUEUE_SIZE", "-1")
+	_, err = ReadConfig(path, func(cfg *configs.ServerConfig) { cfg.Host = "bad" })
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, field := range []string{"host", "shutdown_wait", "jobs.queue_size"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Fatalf("err %q does not mention %s", err, field)
+		}
+	}
+
+	// 密钥引用的环境变量不存在
+	t.Setenv("SIMPLETOOLS_JWT_KEY", "env:SIMPLETOOLS_SECRET_NOT_SET")
+	if _, err = ReadConfig(path, nil); err == nil || !strings.Contains(err.Error(), "jwt_key") {
+		t.Fatalf("unresolved secret err %v", err)
+	}
+}
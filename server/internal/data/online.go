@@ -1,13 +1,60 @@
 package data
 
-import "sync"
+import (
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"sync"
+)
 
+var (
+	ErrSessionNotFound = errors.New("session not found or expired")
+	ErrRefreshReused   = errors.New("refresh token reused, session revoked")
+)
+
+// OnlineUser 一次登录会话，刷新token只保存sha256，每次刷新后替换
 type OnlineUser struct {
-	Platform string
-	Username string
-	Password string
+	SessionId       string `json:"session_id"`
+	Platform        string `json:"platform"`
+	Username        string `json:"username"`
+	LoginAt         int64  `json:"login_at"`
+	RefreshedAt     int64  `json:"refreshed_at"`
+	ExpiresAt       int64  `json:"expires_at"` // 刷新token的过期时间，过期后会话被清理
+	RefreshHash     string `json:"refresh_hash"`
+	AccessId        string `json:"access_id"`         // 最近签发的access token的jti，结束会话时吊销
+	AccessExpiresAt int64  `json:"access_expires_at"` // 最近签发的access token的过期时间
+	ClientIP        string `json:"client_ip"`
+	UserAgent       string `json:"user_agent"`
+}
+
+// Session 返回给客户端的会话信息，不包含刷新token哈希
+type Session struct {
+	SessionId   string `json:"session_id"`
+	Platform    string `json:"platform"`
+	Username    string `json:"username"`
+	LoginAt     int64  `json:"login_at"`
+	RefreshedAt int64  `json:"refreshed_at"`
+	ExpiresAt   int64  `json:"expires_at"`
+	ClientIP    string `json:"client_ip"`
+	UserAgent   string `json:"user_agent"`
+	Current     bool   `json:"current"` // 是否为本次请求使用的会话
 }
 
+func (u *OnlineUser) Session(current string) Session {
+	return Session{
+		SessionId:   u.SessionId,
+		Platform:    u.Platform,
+		Username:    u.Username,
+		LoginAt:     u.LoginAt,
+		RefreshedAt: u.RefreshedAt,
+		ExpiresAt:   u.ExpiresAt,
+		ClientIP:    u.ClientIP,
+		UserAgent:   u.UserAgent,
+		Current:     u.SessionId == current,
+	}
+}
+
+// OnlineUserMgr 按会话id管理登录会话，同一用户可以在多个设备上同时登录；返回的都是副本
 type OnlineUserMgr struct {
 	mu    sync.RWMutex
 	users map[string]*OnlineUser
@@ -27,56 +74,91 @@ func (m *OnlineUserMgr) Add(user *OnlineUser) {
 	if user == nil {
 		return
 	}
+	cp := *user
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.users[hash(user.Username, user.Platform)] = user
+	m.users[cp.SessionId] = &cp
 }
 
-func (m *OnlineUserMgr) Del(user *OnlineUser) {
-	if user == nil {
-		return
-	}
+// Del 删除会话，返回被删除的会话，不存在时返回nil
+func (m *OnlineUserMgr) Del(sessionId string) *OnlineUser {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.users, hash(user.Username, user.Platform))
+	user, ok := m.users[sessionId]
+	if !ok {
+		return nil
+	}
+	delete(m.users, sessionId)
+	return user
 }
 
-func (m *OnlineUserMgr) Get(username, platform string) *OnlineUser {
+func (m *OnlineUserMgr) Get(sessionId string) *OnlineUser {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.users[hash(username, platform)]
+	if user, ok := m.users[sessionId]; ok {
+		cp := *user
+		return &cp
+	}
+	return nil
 }
 
 func (m *OnlineUserMgr) GetAll() []*OnlineUser {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	users := make([]*OnlineUser, 0, len(m.users))
-	for _, user := range m.users {
-		users = append(users, user)
-	}
-	return users
+	return m.filter(func(*OnlineUser) bool { return true })
 }
 
 func (m *OnlineUserMgr) GetByPlatform(platform string) []*OnlineUser {
+	return m.filter(func(u *OnlineUser) bool { return u.Platform == platform })
+}
+
+func (m *OnlineUserMgr) GetByUser(user string) []*OnlineUser {
+	return m.filter(func(u *OnlineUser) bool { return u.Username == user })
+}
+
+// filter 按登录时间排序
+func (m *OnlineUserMgr) filter(fn func(*OnlineUser) bool) []*OnlineUser {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-	users := make([]*OnlineUser, 0, len(m.users))
+	users := make([]*OnlineUser, 0)
 	for _, user := range m.users {
-		if user.Platform == platform {
-			users = append(users, user)
+		if fn(user) {
+			cp := *user
+			users = append(users, &cp)
 		}
 	}
+	m.mu.RUnlock()
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].LoginAt < users[j].LoginAt
+	})
 	return users
 }
 
-func (m *OnlineUserMgr) GetByUser(user string) []*OnlineUser {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	users := make([]*OnlineUser, 0, len(m.users))
-	for _, u := range m.users {
-		if u.Username == user {
-			users = append(users, u)
+// Rotate 校验刷新token的哈希后由update写入新的刷新token和access token；
+// 哈希不一致说明已经轮换过的旧token被再次使用(可能被盗)，删除会话并返回ErrRefreshReused
+func (m *OnlineUserMgr) Rotate(sessionId, refreshHash string, now int64, update func(u *OnlineUser)) (*OnlineUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[sessionId]
+	if !ok || user.ExpiresAt <= now {
+		return nil, ErrSessionNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(user.RefreshHash), []byte(refreshHash)) != 1 {
+		delete(m.users, sessionId)
+		return user, ErrRefreshReused
+	}
+	update(user)
+	cp := *user
+	return &cp, nil
+}
+
+// Clean 删除刷新token已过期的会话
+func (m *OnlineUserMgr) Clean(now int64) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, user := range m.users {
+		if user.ExpiresAt <= now {
+			delete(m.users, id)
+			n++
 		}
 	}
-	return users
+	return n
 }
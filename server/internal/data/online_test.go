@@ -0,0 +1,119 @@
+package data
+
+import (
+	"errors"
+	"simpletools/internal/utils"
+	"testing"
+	"time"
+)
+
+func testSession(id, refresh string, expiresAt int64) *OnlineUser {
+	return &OnlineUser{
+		SessionId:       id,
+		Username:        "alice",
+		Platform:        "web",
+		ExpiresAt:       expiresAt,
+		RefreshHash:     utils.SHA256([]byte(refresh)),
+		AccessId:        id + "-access",
+		AccessExpiresAt: time.Now().Unix() + 600,
+	}
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Now().Unix()
+	m := NewOnlineUserMgr()
+	m.Add(testSession("s1", "r1", now+3600))
+
+	rotate := func(refresh, next string) (*OnlineUser, error) {
+		return m.Rotate("s1", utils.SHA256([]byte(refresh)), now, func(u *OnlineUser) {
+			u.RefreshHash = utils.SHA256([]byte(next))
+			u.AccessId = next + "-access"
+			u.RefreshedAt = now
+		})
+	}
+	user, err := rotate("r1", "r2")
+	if err != nil || user.AccessId != "r2-access" || user.RefreshedAt != now {
+		t.Fatalf("rotate %+v err %v", user, err)
+	}
+	if got := m.Get("s1"); got.RefreshHash != utils.SHA256([]byte("r2")) {
+		t.Fatal("new refresh token not stored")
+	}
+	if user, err = rotate("r2", "r3"); err != nil {
+		t.Fatalf("second rotate err %v", err)
+	}
+
+	// 已经轮换过的旧token再次使用，会话被删除，返回会话用于吊销最近的access token
+	user, err = rotate("r1", "x")
+	if !errors.Is(err, ErrRefreshReused) || user == nil || user.AccessId != "r3-access" {
+		t.Fatalf("reuse returned %+v err %v", user, err)
+	}
+	if m.Get("s1") != nil {
+		t.Fatal("session kept after refresh reuse")
+	}
+	// 会话删除后最新的token也不能再使用
+	if _, err = rotate("r3", "r4"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("rotate after reuse err %v", err)
+	}
+}
+
+func TestRotateExpired(t *testing.T) {
+	now := time.Now().Unix()
+	m := NewOnlineUserMgr()
+	m.Add(testSession("s1", "r1", now))
+	m.Add(testSession("s2", "r2", now+1))
+	called := false
+	update := func(*OnlineUser) { called = true }
+
+	if _, err := m.Rotate("s1", utils.SHA256([]byte("r1")), now, update); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expired session err %v", err)
+	}
+	if _, err := m.Rotate("none", utils.SHA256([]byte("r1")), now, update); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("unknown session err %v", err)
+	}
+	if called {
+		t.Fatal("update called for invalid session")
+	}
+	if n := m.Clean(now); n != 1 || m.Get("s1") != nil || m.Get("s2") == nil {
+		t.Fatalf("clean removed %d sessions", n)
+	}
+}
+
+func TestRevokeList(t *testing.T) {
+	now := time.Now().Unix()
+	r := NewRevokeList()
+	r.Revoke("a", now+10)
+	r.Revoke("b", now+100)
+	r.Revoke("expired", now-1) // 已过期的token本身无效，不需要记录
+	r.Revoke("", now+10)
+	if r.Len() != 2 || !r.Revoked("a") || !r.Revoked("b") || r.Revoked("expired") {
+		t.Fatalf("revoked %v", r.items)
+	}
+
+	if n := r.Clean(now + 9); n != 0 {
+		t.Fatalf("clean before expiry removed %d", n)
+	}
+	if n := r.Clean(now + 10); n != 1 || r.Revoked("a") || !r.Revoked("b") {
+		t.Fatalf("clean at expiry removed %d, left %v", n, r.items)
+	}
+}
+
+func TestKillSession(t *testing.T) {
+	now := time.Now().Unix()
+	GUser, GRevoked = NewOnlineUserMgr(), NewRevokeList()
+	t.Cleanup(func() { GUser, GRevoked = nil, nil })
+	GUser.Add(testSession("s1", "r1", now+3600))
+	GUser.Add(testSession("s2", "r2", now+3600))
+	other := testSession("s3", "r3", now+3600)
+	other.Username = "bob"
+	GUser.Add(other)
+
+	if !KillSession("s1") || KillSession("s1") {
+		t.Fatal("kill session")
+	}
+	if GUser.Get("s1") != nil || !GRevoked.Revoked("s1-access") {
+		t.Fatal("session not removed or access token not revoked")
+	}
+	if n := KillUserSessions("alice"); n != 1 || !GRevoked.Revoked("s2-access") || GUser.Get("s3") == nil {
+		t.Fatalf("kill user sessions %d", n)
+	}
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"os"
+	"simpletools/internal/configs"
+	"time"
+)
+
+// 热加载需要的启动参数，只在主线程读写
+var (
+	reloadConfig   string
+	reloadOverride func(*configs.ServerConfig)
+	reloadModTime  time.Time
+)
+
+func initReload(useConfig string, override func(*configs.ServerConfig)) {
+	reloadConfig, reloadOverride = useConfig, override
+	reloadModTime = configModTime()
+}
+
+func configModTime() time.Time {
+	path, err := ConfigPath(reloadConfig)
+	if err != nil {
+		return time.Time{}
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return stat.ModTime()
+}
+
+// ConfigModified 配置文件的修改时间是否变化，文件暂时不存在时(编辑器替换文件)视为未变化
+func ConfigModified() bool {
+	t := configModTime()
+	return !t.IsZero() && !t.Equal(reloadModTime)
+}
+
+// ReloadConfig 重新读取配置，校验通过后替换GConfig并让可热加载的配置项立即生效，失败时保留当前配置
+// 需要重启的配置项保留启动时的值，日志中会列出这些配置项
+func ReloadConfig(reason string) error {
+	reloadModTime = configModTime()
+	prev := Config()
+	cur, err := ReadConfig(reloadConfig, reloadOverride)
+	if err != nil {
+		Log().Error().Err(err).Str("reason", reason).Msg("reload config failed, keep current config")
+		return err
+	}
+	changed := configs.Diff(prev, cur)
+	if len(changed) == 0 {
+		Log().Info().Str("reason", reason).Msg("reload config, nothing changed")
+		return nil
+	}
+	merged := configs.Merge(prev, cur)
+	if err = merged.Validate(); err != nil { // 新的配置项与保留的旧配置项组合后不合法
+		Log().Error().Err(err).Str("reason", reason).Msg("reload config conflicts with restart-only fields, keep current config")
+		return err
+	}
+	if err = GLLM.Reload(merged.LLM); err != nil { // 提供方配置有误时整体放弃本次加载
+		Log().Error().Err(err).Str("reason", reason).Msg("reload llm config failed, keep current config")
+		return err
+	}
+	GConfig.Store(merged)
+	GLog.SetLevel(merged.Logger.LogLevel)
+	GUsage.SetConfig(merged.LLM.Quota)
+
+	var applied, restart []string
+	for _, field := range changed {
+		if configs.Reloadable(field) {
+			applied = append(applied, field)
+		} else {
+			restart = append(restart, field)
+		}
+	}
+	e := Log().Info()
+	if len(restart) > 0 {
+		e = Log().Warn()
+	}
+	e.Str("reason", reason).Strs("applied", applied).Strs("restart_required", restart).Msg("reload config")
+	return nil
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"simpletools/internal/utils"
+	"sync"
+	"time"
+)
+
+// RevokeList 已吊销的access token，按jti记录到token过期为止，过期后token本身已无效，可以清理
+type RevokeList struct {
+	mu    sync.RWMutex
+	items map[string]int64 // jti -> token过期时间
+}
+
+func NewRevokeList() *RevokeList {
+	return &RevokeList{items: make(map[string]int64)}
+}
+
+func (r *RevokeList) Revoke(jti string, expiresAt int64) {
+	if jti == "" || expiresAt <= time.Now().Unix() {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[jti] = expiresAt
+}
+
+func (r *RevokeList) Revoked(jti string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.items[jti]
+	return ok
+}
+
+func (r *RevokeList) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.items)
+}
+
+func (r *RevokeList) Clean(now int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for jti, expiresAt := range r.items {
+		if expiresAt <= now {
+			delete(r.items, jti)
+			n++
+		}
+	}
+	return n
+}
+
+// KillSession 结束会话：删除会话使刷新token失效，并吊销最近签发的access token
+func KillSession(sessionId string) bool {
+	user := GUser.Del(sessionId)
+	if user == nil {
+		return false
+	}
+	GRevoked.Revoke(user.AccessId, user.AccessExpiresAt)
+	return true
+}
+
+// KillUserSessions 结束用户的所有会话，返回结束的数量
+func KillUserSessions(username string) int {
+	n := 0
+	for _, user := range GUser.GetByUser(username) {
+		if KillSession(user.SessionId) {
+			n++
+		}
+	}
+	return n
+}
+
+type sessionsFile struct {
+	Sessions []*OnlineUser    `json:"sessions"`
+	Revoked  map[string]int64 `json:"revoked"`
+}
+
+// SaveSessions 退出前保存会话和吊销列表，重启后已登录的用户不需要重新登录，已吊销的token也不会恢复
+func SaveSessions() error {
+	path := Config().Auth.SessionsPath
+	if path == "" {
+		return nil
+	}
+	f := sessionsFile{Sessions: GUser.GetAll()}
+	GRevoked.mu.RLock()
+	f.Revoked = make(map[string]int64, len(GRevoked.items))
+	for jti, expiresAt := range GRevoked.items {
+		f.Revoked[jti] = expiresAt
+	}
+	GRevoked.mu.RUnlock()
+	bs, err := jsoniter.Marshal(f)
+	if err != nil {
+		return err
+	}
+	Log().Info().Int("sessions", len(f.Sessions)).Int("revoked", len(f.Revoked)).Str("path", path).Msg("save sessions")
+	return utils.WriteFileAtomic(path, bs, 0600)
+}
+
+// loadSessions 读取上次退出时保存的会话，跳过已过期的
+func loadSessions(path string) error {
+	if path == "" {
+		return nil
+	}
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f sessionsFile
+	if err = jsoniter.Unmarshal(bs, &f); err != nil {
+		return fmt.Errorf("sessions file %s: %w", path, err)
+	}
+	for _, user := range f.Sessions {
+		GUser.Add(user)
+	}
+	for jti, expiresAt := range f.Revoked {
+		GRevoked.Revoke(jti, expiresAt)
+	}
+	now := time.Now().Unix()
+	GUser.Clean(now)
+	return nil
+}
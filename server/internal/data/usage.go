@@ -0,0 +1,243 @@
+package data
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"simpletools/internal/configs"
+	"simpletools/internal/llm"
+	"simpletools/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	usageDayFormat       = "20060102"
+	defaultUsageKeepDays = 31
+	defaultAnonymousMax  = 10000
+	AnonymousUser        = "anonymous" // 未登录的请求按客户端分别计入 anonymous:客户端，额度按该用户名配置
+)
+
+type UsageStat struct {
+	Username         string `json:"username"`
+	Platform         string `json:"platform"`
+	Day              string `json:"day"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	CacheHitTokens   int64  `json:"cache_hit_tokens"` // 提示词命中提供方缓存的token数
+	TotalTokens      int64  `json:"total_tokens"`
+	Quota            int64  `json:"quota"` // 当日token额度，0表示不限制
+}
+
+// UsageMgr 按 天+用户+平台 聚合大模型用量，并在请求前检查每日额度
+type UsageMgr struct {
+	mu        sync.RWMutex
+	cfg       configs.LLMQuotaConfig
+	days      map[string]map[string]*UsageStat // day -> user hash -> stat
+	order     []string                         // 已有统计的日期，升序
+	anonymous map[string]int                   // day -> 单独统计的未登录来源数
+}
+
+func NewUsageMgr(cfg configs.LLMQuotaConfig) *UsageMgr {
+	m := &UsageMgr{days: make(map[string]map[string]*UsageStat), anonymous: make(map[string]int)}
+	m.SetConfig(cfg)
+	return m
+}
+
+// SetConfig 修改额度配置，已有的用量统计保留，配置热加载时调用
+func (m *UsageMgr) SetConfig(cfg configs.LLMQuotaConfig) {
+	if cfg.KeepDays <= 0 {
+		cfg.KeepDays = defaultUsageKeepDays
+	}
+	if cfg.AnonymousMax <= 0 {
+		cfg.AnonymousMax = defaultAnonymousMax
+	}
+	m.mu.Lock()
+	m.cfg = cfg
+	m.mu.Unlock()
+}
+
+// UsageToday 用量统计使用的日期，本地时间 20060102
+func UsageToday() string {
+	return Now().Format(usageDayFormat)
+}
+
+// UsageUser 用量统计使用的用户名，未登录时按client(签名的客户端id或ip)区分，单个客户端不能用完其他未登录客户端的额度
+func UsageUser(username, client string) string {
+	if username != "" {
+		return username
+	}
+	if client == "" {
+		return AnonymousUser
+	}
+	return AnonymousUser + ":" + client
+}
+
+// Quota 用户的每日token额度，单独配置优先于默认值，0表示不限制；username为UsageUser的结果
+func (m *UsageMgr) Quota(username, platform string) int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.quota(username, platform)
+}
+
+func (m *UsageMgr) quota(username, platform string) int64 {
+	if strings.HasPrefix(username, AnonymousUser+":") { // 每个未登录的客户端分别使用anonymous的额度
+		username = AnonymousUser
+	}
+	if q, ok := m.cfg.UserDailyTokens[hash(username, platform)]; ok {
+		return q
+	}
+	if q, ok := m.cfg.UserDailyTokens[username]; ok {
+		return q
+	}
+	return m.cfg.DailyTokens
+}
+
+// resolve 当天单独统计的未登录来源达到上限后，新的来源合并计入anonymous，避免统计随来源数量无限增长；调用方持有锁
+func (m *UsageMgr) resolve(day, username, platform string) string {
+	if !strings.HasPrefix(username, AnonymousUser+":") {
+		return username
+	}
+	if _, ok := m.days[day][hash(username, platform)]; ok || m.anonymous[day] < m.cfg.AnonymousMax {
+		return username
+	}
+	return AnonymousUser
+}
+
+// Exhausted 当日额度是否已用完，并发请求可能略微超出额度，只保证用完后不再放行
+func (m *UsageMgr) Exhausted(username, platform string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	day := UsageToday()
+	username = m.resolve(day, username, platform)
+	quota := m.quota(username, platform)
+	if quota <= 0 {
+		return false
+	}
+	stat := m.days[day][hash(username, platform)]
+	return stat != nil && stat.TotalTokens >= quota
+}
+
+// Record 记录一次请求提供方的用量，命中结果缓存的请求不调用
+func (m *UsageMgr) Record(username, platform string, usage llm.Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	day := UsageToday()
+	stat := m.stat(day, m.resolve(day, username, platform), platform)
+	stat.Requests++
+	stat.PromptTokens += int64(usage.PromptTokens)
+	stat.CompletionTokens += int64(usage.CompletionTokens)
+	stat.CacheHitTokens += int64(usage.CacheHitTokens)
+	stat.TotalTokens += int64(usage.TotalTokens)
+}
+
+// stat 找到或新建统计项，新的日期超过保留天数时删除最早的日期；调用方持有写锁
+func (m *UsageMgr) stat(day, username, platform string) *UsageStat {
+	users, ok := m.days[day]
+	if !ok {
+		users = make(map[string]*UsageStat)
+		m.days[day] = users
+		m.order = append(m.order, day)
+		sort.Strings(m.order)
+		for len(m.order) > m.cfg.KeepDays {
+			delete(m.days, m.order[0])
+			delete(m.anonymous, m.order[0])
+			m.order = m.order[1:]
+		}
+	}
+	key := hash(username, platform)
+	stat, ok := users[key]
+	if !ok {
+		stat = &UsageStat{Username: username, Platform: platform, Day: day}
+		users[key] = stat
+		if strings.HasPrefix(username, AnonymousUser+":") {
+			m.anonymous[day]++
+		}
+	}
+	return stat
+}
+
+// GetByDay 某天的用量，username为空时返回全部用户，day为空时为今天
+func (m *UsageMgr) GetByDay(day, username string) []*UsageStat {
+	if day == "" {
+		day = UsageToday()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	users := m.days[day]
+	stats := make([]*UsageStat, 0, len(users))
+	for _, stat := range users {
+		if username != "" && stat.Username != username {
+			continue
+		}
+		cp := *stat
+		cp.Quota = m.quota(stat.Username, stat.Platform)
+		stats = append(stats, &cp)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].TotalTokens > stats[j].TotalTokens
+	})
+	return stats
+}
+
+// Days 有统计数据的日期，升序
+func (m *UsageMgr) Days() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.order...)
+}
+
+// SaveUsage 退出前保存用量统计，重启后额度不会重置
+func SaveUsage() error {
+	path := Config().LLM.Quota.PersistPath
+	if path == "" {
+		return nil
+	}
+	GUsage.mu.RLock()
+	stats := make([]*UsageStat, 0)
+	for _, day := range GUsage.order {
+		for _, stat := range GUsage.days[day] {
+			cp := *stat
+			stats = append(stats, &cp)
+		}
+	}
+	GUsage.mu.RUnlock()
+	bs, err := jsoniter.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	Log().Info().Int("stats", len(stats)).Str("path", path).Msg("save usage")
+	return utils.WriteFileAtomic(path, bs, 0644)
+}
+
+// loadUsage 读取上次退出时保存的用量统计，超过保留天数的日期被丢弃
+func loadUsage(path string) error {
+	if path == "" {
+		return nil
+	}
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var stats []*UsageStat
+	if err = jsoniter.Unmarshal(bs, &stats); err != nil {
+		return fmt.Errorf("usage file %s: %w", path, err)
+	}
+	GUsage.mu.Lock()
+	defer GUsage.mu.Unlock()
+	oldest := Now().AddDate(0, 0, 1-GUsage.cfg.KeepDays).Format(usageDayFormat)
+	for _, s := range stats {
+		if s.Day < oldest {
+			continue
+		}
+		stat := GUsage.stat(s.Day, s.Username, s.Platform)
+		*stat = *s
+		stat.Quota = 0
+	}
+	return nil
+}
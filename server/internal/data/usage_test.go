@@ -0,0 +1,77 @@
+package data
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"path/filepath"
+	"simpletools/internal/configs"
+	"simpletools/internal/llm"
+	"testing"
+)
+
+var testUsage = llm.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}
+
+func TestAnonymousOverflow(t *testing.T) {
+	m := NewUsageMgr(configs.LLMQuotaConfig{AnonymousMax: 2, UserDailyTokens: map[string]int64{AnonymousUser: 10}})
+	for _, client := range []string{"ip:1", "ip:2", "ip:3", "ip:4", "ip:1"} {
+		m.Record(UsageUser("", client), "web", testUsage)
+	}
+	m.Record("alice", "web", testUsage)
+
+	got := map[string]int64{}
+	for _, stat := range m.GetByDay("", "") {
+		got[stat.Username] = stat.Requests
+	}
+	want := map[string]int64{"anonymous:ip:1": 2, "anonymous:ip:2": 1, AnonymousUser: 2, "alice": 1}
+	if len(got) != len(want) {
+		t.Fatalf("stats %v, want %v", got, want)
+	}
+	for username, requests := range want {
+		if got[username] != requests {
+			t.Fatalf("stats %v, want %v", got, want)
+		}
+	}
+
+	// 超出上限的来源共用anonymous的额度，已单独统计的来源不受影响
+	cases := []struct {
+		client    string
+		exhausted bool
+	}{{"ip:1", true}, {"ip:2", false}, {"ip:3", true}, {"ip:5", true}}
+	for _, tc := range cases {
+		if m.Exhausted(UsageUser("", tc.client), "web") != tc.exhausted {
+			t.Fatalf("client %s exhausted:%v", tc.client, !tc.exhausted)
+		}
+	}
+}
+
+func TestLoadUsagePrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	today := Now()
+	day := func(offset int) string {
+		return today.AddDate(0, 0, offset).Format(usageDayFormat)
+	}
+	stats := []*UsageStat{
+		{Username: "alice", Platform: "web", Day: day(-5), Requests: 1},
+		{Username: "alice", Platform: "web", Day: day(-2), Requests: 2},
+		{Username: "alice", Platform: "web", Day: day(0), Requests: 3, TotalTokens: 9, Quota: 100},
+	}
+	bs, _ := jsoniter.Marshal(stats)
+	if err := os.WriteFile(path, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prev := GUsage
+	defer func() { GUsage = prev }()
+	GUsage = NewUsageMgr(configs.LLMQuotaConfig{KeepDays: 3})
+	if err := loadUsage(path); err != nil {
+		t.Fatal(err)
+	}
+	days := GUsage.Days()
+	if len(days) != 2 || days[0] != day(-2) || days[1] != day(0) {
+		t.Fatalf("days %v, want %s and %s", days, day(-2), day(0))
+	}
+	today0 := GUsage.GetByDay(day(0), "alice")
+	if len(today0) != 1 || today0[0].Requests != 3 || today0[0].TotalTokens != 9 || today0[0].Quota != 0 {
+		t.Fatalf("today stats %+v", today0)
+	}
+}
@@ -14,6 +14,8 @@ const (
 	EssRedis    EEventSinkSource = 1
 	EssMysql    EEventSinkSource = 2
 	EssGSignalQ EEventSinkSource = 3
+	EssJob      EEventSinkSource = 4 // 异步任务提交
+	EssReload   EEventSinkSource = 5 // 重新加载配置，Payload为触发原因
 )
 
 type LanguageType int
@@ -30,4 +32,32 @@ const (
 	VariableTypeCamelCase  VariableType = 2
 	VariableTypeSnakeCase  VariableType = 3
 	VariableTypeUpperCase  VariableType = 4
+	VariableTypeKebabCase  VariableType = 5 // css类名、命令行参数
+	VariableTypeDotCase    VariableType = 6 // 配置项key
+)
+
+// CodeLanguage 命名的目标编程语言
+type CodeLanguage = string
+
+const (
+	CodeLanguageGo         CodeLanguage = "go"
+	CodeLanguageTypeScript CodeLanguage = "typescript"
+	CodeLanguagePython     CodeLanguage = "python"
+	CodeLanguageRust       CodeLanguage = "rust"
+	CodeLanguageSQL        CodeLanguage = "sql"
+	CodeLanguageCSS        CodeLanguage = "css"
+)
+
+// IdentifierKind 命名对象的种类
+type IdentifierKind = string
+
+const (
+	IdentifierKindFunction  IdentifierKind = "function"
+	IdentifierKindVariable  IdentifierKind = "variable"
+	IdentifierKindConstant  IdentifierKind = "constant"
+	IdentifierKindType      IdentifierKind = "type"
+	IdentifierKindFile      IdentifierKind = "file"
+	IdentifierKindColumn    IdentifierKind = "column"     // 数据库字段
+	IdentifierKindFlag      IdentifierKind = "flag"       // 命令行参数
+	IdentifierKindConfigKey IdentifierKind = "config_key" // 配置项key
 )
@@ -7,6 +7,17 @@ const (
 	ErrCodeSystemPanic      ErrCode = 1 // 系统崩溃
 	ErrCodeSystemError      ErrCode = 2 // 系统错误
 	ErrCodeRequestParamsErr ErrCode = 3 // 请求参数错误
+	ErrCodeQuotaExhausted   ErrCode = 4 // 用户当日大模型额度已用完
+	ErrCodePermissionDenied ErrCode = 5 // 没有权限
+	ErrCodeLoginFailed      ErrCode = 6 // 用户名或密码错误
+	ErrCodeSessionInvalid   ErrCode = 7 // 刷新token无效或会话已结束，需要重新登录
+
+	ErrCodeLLMUpstreamError   ErrCode = 100 // 大模型接口返回了无法归类的错误
+	ErrCodeLLMQuotaExceeded   ErrCode = 101 // 大模型账户余额或额度不足
+	ErrCodeLLMRateLimited     ErrCode = 102 // 大模型接口限流，重试后仍失败
+	ErrCodeLLMUpstreamTimeout ErrCode = 103 // 大模型接口超时
+	ErrCodeLLMContentFiltered ErrCode = 104 // 内容被大模型安全策略过滤
+	ErrCodeLLMEmptyAnswer     ErrCode = 105 // 大模型没有返回任何内容
 )
 
 type CustomError struct {
@@ -0,0 +1,114 @@
+package document
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"simpletools/internal/translate"
+	"strings"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatPO       = "po"
+	FormatSRT      = "srt"
+)
+
+var newlineRe = regexp.MustCompile(`\s*\n\s*`)
+
+var formatExts = map[string]string{
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+	".json":     FormatJSON,
+	".yaml":     FormatYAML,
+	".yml":      FormatYAML,
+	".po":       FormatPO,
+	".pot":      FormatPO,
+	".srt":      FormatSRT,
+}
+
+// Document 解析后的文档，只暴露需要翻译的文本，翻译后按原格式重新生成
+type Document interface {
+	Segments() []string                         // 需要翻译的文本，按出现顺序
+	Render(translated []string) (string, error) // translated与Segments一一对应
+}
+
+// FormatFromName 根据文件扩展名判断格式，无法判断时返回空
+func FormatFromName(name string) string {
+	return formatExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// Parse 按格式解析文档
+func Parse(format, content string) (Document, error) {
+	switch strings.ToLower(format) {
+	case FormatMarkdown, "md":
+		return parseMarkdown(content), nil
+	case FormatJSON:
+		return parseJSON(content)
+	case FormatYAML, "yml":
+		return parseYAML(content)
+	case FormatPO, "pot":
+		return parsePO(content)
+	case FormatSRT:
+		return parseSRT(content), nil
+	}
+	return nil, fmt.Errorf("unsupported document format:%s", format)
+}
+
+// piece 模板中的一段，seg为-1时原样输出text，否则输出第seg个片段的译文
+type piece struct {
+	text string
+	seg  int
+}
+
+// template 由原样保留的文本和待翻译片段交替组成，用于按行处理的文本格式
+type template struct {
+	pieces   []piece
+	segments []string
+	oneLine  bool // 译文中的换行替换为空格，避免破坏按行解析的格式
+}
+
+func (t *template) literal(s string) {
+	if s != "" {
+		t.pieces = append(t.pieces, piece{text: s, seg: -1})
+	}
+}
+
+// segment 首尾空白原样保留，不需要翻译的内容按原文输出
+func (t *template) segment(s string) {
+	core := strings.TrimSpace(s)
+	if !translate.Translatable(core) {
+		t.literal(s)
+		return
+	}
+	start := strings.Index(s, core)
+	t.literal(s[:start])
+	t.pieces = append(t.pieces, piece{seg: len(t.segments)})
+	t.segments = append(t.segments, core)
+	t.literal(s[start+len(core):])
+}
+
+func (t *template) Segments() []string {
+	return t.segments
+}
+
+func (t *template) Render(translated []string) (string, error) {
+	if len(translated) != len(t.segments) {
+		return "", fmt.Errorf("translated %d segments, want %d", len(translated), len(t.segments))
+	}
+	var sb strings.Builder
+	for _, p := range t.pieces {
+		if p.seg < 0 {
+			sb.WriteString(p.text)
+		} else {
+			text := translated[p.seg]
+			if t.oneLine {
+				text = newlineRe.ReplaceAllString(strings.TrimSpace(text), " ")
+			}
+			sb.WriteString(text)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,268 @@
+package document
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testMarkdown = `---
+title: Hello
+---
+# Getting started
+
+Install the ` + "`simpletools`" + ` binary, see [docs](https://example.com/docs "Docs").
+
+` + "```go" + `
+fmt.Println("not translated")
+` + "```" + `
+
+> - [x] Done item
+1. First step
+
+| Name | Value |
+| :--- | ---: |
+| Speed | 10 km/h |
+| Escaped \| pipe | 42 |
+
+***
+[docs]: https://example.com/docs
+<!-- comment -->
+`
+	testJSON = `{
+  "title": "Hello",
+  "count": 3,
+  "enabled": true,
+  "empty": null,
+  "nested": {
+    "html": "<b>Bold</b> & more",
+    "list": [
+      "One",
+      "2",
+      "{name}"
+    ]
+  }
+}
+`
+	testYAML = `# config
+title: Hello world
+count: 3
+items:
+  - First item
+  - "Quoted item"
+  - 42
+---
+other: Second doc
+`
+	testSRT = `1
+00:00:01,000 --> 00:00:02,000
+Hello there
+
+2
+00:00:03,000 --> 00:00:04,000
+First line
+Second line
+
+3
+00:00:05,000 --> 00:00:06,000
+♪ 123 ♪
+`
+)
+
+func TestRoundTrip(t *testing.T) {
+	cases := []struct {
+		format   string
+		content  string
+		segments []string
+	}{
+		{FormatMarkdown, testMarkdown, []string{
+			"Getting started",
+			"Install the `simpletools` binary, see [docs](https://example.com/docs \"Docs\").",
+			"Done item", "First step",
+			"Name", "Value", "Speed", "10 km/h", `Escaped \| pipe`,
+		}},
+		{FormatJSON, testJSON, []string{"Hello", "<b>Bold</b> & more", "One"}},
+		{FormatJSON, `{"a":"Hello","b":[1,"World"]}`, []string{"Hello", "World"}},
+		{FormatYAML, testYAML, []string{"Hello world", "First item", "Quoted item", "Second doc"}},
+		{FormatSRT, testSRT, []string{"Hello there", "First line\nSecond line"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.format, func(t *testing.T) {
+			doc, err := Parse(tc.format, tc.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if segments := doc.Segments(); !reflect.DeepEqual(segments, tc.segments) {
+				t.Fatalf("segments %q, want %q", segments, tc.segments)
+			}
+			out, err := doc.Render(doc.Segments())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tc.content {
+				t.Fatalf("identity render changed the document:\n%s\nwant:\n%s", out, tc.content)
+			}
+			if _, err = doc.Render(nil); err == nil && len(tc.segments) > 0 {
+				t.Fatal("render with wrong segment count succeeded")
+			}
+		})
+	}
+}
+
+// upper 模拟翻译，逐个片段加前缀
+func upper(segments []string) []string {
+	out := make([]string, len(segments))
+	for i, s := range segments {
+		out[i] = "T:" + strings.ToUpper(s)
+	}
+	return out
+}
+
+func render(t *testing.T, format, content string, translate func([]string) []string) string {
+	t.Helper()
+	doc, err := Parse(format, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := doc.Render(translate(doc.Segments()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestRenderTranslated(t *testing.T) {
+	cases := []struct {
+		format  string
+		content string
+		want    string
+	}{
+		{FormatMarkdown, "## Title\r\n- item one\n\n```\ncode\n```\n", "## T:TITLE\r\n- T:ITEM ONE\n\n```\ncode\n```\n"},
+		{FormatMarkdown, "| a | b |\n|---|---|\n| c | 1 |\n", "| T:A | T:B |\n|---|---|\n| T:C | 1 |\n"},
+		{FormatMarkdown, "---\ntitle: x\n---\ntext", "---\ntitle: x\n---\nT:TEXT"},
+		{FormatMarkdown, "~~~\n```\nnot closed by backticks\n~~~\nafter", "~~~\n```\nnot closed by backticks\n~~~\nT:AFTER"},
+		{FormatJSON, `{"k":"v","n":"1"}`, `{"k":"T:V","n":"1"}`},
+		{FormatYAML, "a: hello\nb: 'single'\n", "a: T:HELLO\nb: 'T:SINGLE'\n"},
+		{FormatSRT, "\uFEFF1\r\n00:00:01,000 --> 00:00:02,000\r\nHi\r\n", "1\n00:00:01,000 --> 00:00:02,000\nT:HI\n"},
+	}
+	for _, tc := range cases {
+		if got := render(t, tc.format, tc.content, upper); got != tc.want {
+			t.Fatalf("%s render %q:\n%q\nwant:\n%q", tc.format, tc.content, got, tc.want)
+		}
+	}
+
+	// 按行解析的markdown中译文的换行替换为空格
+	got := render(t, FormatMarkdown, "# Title\n", func([]string) []string { return []string{"  line one\n  line two "} })
+	if got != "# line one line two\n" {
+		t.Fatalf("multi-line translation rendered as %q", got)
+	}
+}
+
+func TestPO(t *testing.T) {
+	content := `# header comment
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#: main.go:1
+msgid "Hello"
+msgstr ""
+
+msgctxt "menu"
+msgid "Open"
+msgstr "已有译文"
+
+msgid "One file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+
+msgid ""
+"Multi\n"
+"line \"quoted\""
+msgstr ""
+`
+	doc, err := Parse(FormatPO, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Hello", "One file", "%d files", "Multi\nline \"quoted\""}
+	if !reflect.DeepEqual(doc.Segments(), want) {
+		t.Fatalf("segments %q, want %q", doc.Segments(), want)
+	}
+	out, err := doc.Render([]string{"你好", "一个文件", "%d个文件", "多\n行 \"引号\""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOut := `# header comment
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#: main.go:1
+msgid "Hello"
+msgstr "你好"
+
+msgctxt "menu"
+msgid "Open"
+msgstr "已有译文"
+
+msgid "One file"
+msgid_plural "%d files"
+msgstr[0] "一个文件"
+msgstr[1] "%d个文件"
+
+msgid ""
+"Multi\n"
+"line \"quoted\""
+msgstr ""
+"多\n"
+"行 \"引号\""
+`
+	if out != wantOut {
+		t.Fatalf("render:\n%s\nwant:\n%s", out, wantOut)
+	}
+
+	// 没有需要翻译的条目时原样输出
+	if got := render(t, FormatPO, wantOut, upper); got != wantOut {
+		t.Fatalf("translated po changed:\n%s", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		format  string
+		content string
+	}{
+		{FormatJSON, `{"a":1} {"b":2}`},
+		{FormatJSON, `{"a":`},
+		{FormatYAML, "a: [1, 2"},
+		{FormatPO, "msgid \"a\"\nmsgstr \"b\nunterminated"},
+		{FormatPO, "garbage line"},
+		{"docx", "content"},
+	}
+	for _, tc := range cases {
+		if _, err := Parse(tc.format, tc.content); err == nil {
+			t.Fatalf("parse %s %q succeeded", tc.format, tc.content)
+		}
+	}
+}
+
+func TestFormatFromName(t *testing.T) {
+	cases := map[string]string{
+		"README.md":   FormatMarkdown,
+		"zh.JSON":     FormatJSON,
+		"app.yml":     FormatYAML,
+		"messages.po": FormatPO,
+		"base.pot":    FormatPO,
+		"movie.srt":   FormatSRT,
+		"notes.txt":   "",
+		"Makefile":    "",
+	}
+	for name, want := range cases {
+		if got := FormatFromName(name); got != want {
+			t.Fatalf("FormatFromName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package document
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"simpletools/internal/translate"
+	"strings"
+)
+
+var jsonIndentRe = regexp.MustCompile(`\n([ \t]+)\S`)
+
+// jsonNode 保持key顺序的JSON树，只翻译字符串值，key、数字、布尔值原样保留
+type jsonNode struct {
+	kind  byte // '{' '[' 's'字符串 'v'其他值
+	keys  []string
+	items []*jsonNode
+	str   string
+	raw   string
+	seg   int // 字符串值对应的片段下标，-1表示不翻译
+}
+
+type jsonDoc struct {
+	root     *jsonNode
+	indent   string // 原文件的缩进，为空时输出紧凑格式
+	segments []string
+}
+
+func parseJSON(content string) (Document, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+	d := &jsonDoc{}
+	root, err := d.parse(dec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid json: unexpected content after root value")
+	}
+	d.root = root
+	if m := jsonIndentRe.FindStringSubmatch(content); m != nil {
+		d.indent = m[1]
+	}
+	return d, nil
+}
+
+func (d *jsonDoc) parse(dec *json.Decoder) (*jsonNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		node := &jsonNode{kind: byte(v)}
+		for dec.More() {
+			if node.kind == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			item, err := d.parse(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, item)
+		}
+		if _, err = dec.Token(); err != nil { // 结束的 } 或 ]
+			return nil, err
+		}
+		return node, nil
+	case string:
+		node := &jsonNode{kind: 's', str: v, seg: -1}
+		if translate.Translatable(v) {
+			node.seg = len(d.segments)
+			d.segments = append(d.segments, v)
+		}
+		return node, nil
+	case nil:
+		return &jsonNode{kind: 'v', raw: "null"}, nil
+	default:
+		return &jsonNode{kind: 'v', raw: fmt.Sprint(v)}, nil
+	}
+}
+
+func (d *jsonDoc) Segments() []string {
+	return d.segments
+}
+
+func (d *jsonDoc) Render(translated []string) (string, error) {
+	if len(translated) != len(d.segments) {
+		return "", fmt.Errorf("translated %d segments, want %d", len(translated), len(d.segments))
+	}
+	var buf bytes.Buffer
+	d.write(&buf, d.root, translated, 0)
+	if d.indent != "" {
+		buf.WriteByte('\n')
+	}
+	return buf.String(), nil
+}
+
+func (d *jsonDoc) write(buf *bytes.Buffer, node *jsonNode, translated []string, depth int) {
+	switch node.kind {
+	case 's':
+		if node.seg >= 0 {
+			writeJSONString(buf, translated[node.seg])
+		} else {
+			writeJSONString(buf, node.str)
+		}
+		return
+	case 'v':
+		buf.WriteString(node.raw)
+		return
+	}
+	end := byte('}')
+	if node.kind == '[' {
+		end = ']'
+	}
+	buf.WriteByte(node.kind)
+	for i, item := range node.items {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		d.newline(buf, depth+1)
+		if node.kind == '{' {
+			writeJSONString(buf, node.keys[i])
+			buf.WriteByte(':')
+			if d.indent != "" {
+				buf.WriteByte(' ')
+			}
+		}
+		d.write(buf, item, translated, depth+1)
+	}
+	if len(node.items) > 0 {
+		d.newline(buf, depth)
+	}
+	buf.WriteByte(end)
+}
+
+func (d *jsonDoc) newline(buf *bytes.Buffer, depth int) {
+	if d.indent == "" {
+		return
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(strings.Repeat(d.indent, depth))
+}
+
+// writeJSONString 不转义 < > &，保持与手写的语言包一致
+func writeJSONString(buf *bytes.Buffer, s string) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	buf.Write(bytes.TrimRight(b.Bytes(), "\n"))
+}
@@ -0,0 +1,75 @@
+package document
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdFenceRe     = regexp.MustCompile("^\\s*(```|~~~)")
+	mdPrefixRe    = regexp.MustCompile(`^\s*(?:>\s*)*(?:#{1,6}\s+|[-*+]\s+(?:\[[ xX]\]\s+)?|\d+[.)]\s+)?`)
+	mdTableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdRuleRe      = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,}|=+)\s*$`)
+	mdLinkDefRe   = regexp.MustCompile(`^\s*\[[^\]]+\]:\s*\S+`)
+	mdHtmlBlockRe = regexp.MustCompile(`^\s*<!--.*-->\s*$`)
+)
+
+// parseMarkdown 按行处理，代码块、front matter、分隔线和链接定义原样保留，标题、列表、引用只翻译标记之后的文字，表格逐个单元格翻译
+func parseMarkdown(content string) Document {
+	t := &template{oneLine: true}
+	lines := strings.SplitAfter(content, "\n")
+	fence := ""
+	frontMatter := len(lines) > 0 && strings.TrimSpace(lines[0]) == "---"
+	for i, line := range lines {
+		text := strings.TrimRight(line, "\r\n")
+		eol := line[len(text):]
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case frontMatter:
+			t.literal(line)
+			if i > 0 && trimmed == "---" {
+				frontMatter = false
+			}
+			continue
+		case fence != "":
+			t.literal(line)
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := mdFenceRe.FindStringSubmatch(text); m != nil {
+			fence = m[1]
+			t.literal(line)
+			continue
+		}
+		switch {
+		case trimmed == "" || mdTableSepRe.MatchString(text) || mdRuleRe.MatchString(text) ||
+			mdLinkDefRe.MatchString(text) || mdHtmlBlockRe.MatchString(text):
+			t.literal(line)
+		case strings.HasPrefix(trimmed, "|"):
+			markdownTableRow(t, text)
+			t.literal(eol)
+		default:
+			prefix := mdPrefixRe.FindString(text)
+			t.literal(prefix)
+			t.segment(text[len(prefix):])
+			t.literal(eol)
+		}
+	}
+	return t
+}
+
+// markdownTableRow 按未转义的 | 拆分单元格
+func markdownTableRow(t *template, text string) {
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '|' || (i > 0 && text[i-1] == '\\') {
+			continue
+		}
+		t.segment(text[start:i])
+		t.literal("|")
+		start = i + 1
+	}
+	t.segment(text[start:])
+}
@@ -0,0 +1,184 @@
+package document
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	poKeywordRe = regexp.MustCompile(`^(msgctxt|msgid|msgid_plural|msgstr(?:\[\d+\])?)\s+(".*")\s*$`)
+	poStringRe  = regexp.MustCompile(`^\s*(".*")\s*$`)
+)
+
+// poField 条目中的一个关键字及其值，start/end为在文件中的行范围
+type poField struct {
+	keyword    string
+	value      string
+	start, end int
+}
+
+// poEntry 一条翻译，只翻译msgstr为空的条目，已有译文和文件头原样保留
+type poEntry struct {
+	fields []*poField
+	msgid  *poField
+	plural *poField
+	idSeg  int
+	plSeg  int
+}
+
+type poDoc struct {
+	lines    []string
+	entries  []*poEntry
+	segments []string
+}
+
+func parsePO(content string) (Document, error) {
+	d := &poDoc{lines: strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")}
+	var entry *poEntry
+	var field *poField
+	for i, line := range d.lines {
+		trimmed := strings.TrimSpace(line)
+		if m := poKeywordRe.FindStringSubmatch(line); m != nil {
+			if entry == nil || m[1] == "msgctxt" || (m[1] == "msgid" && entry.msgid != nil) {
+				entry = &poEntry{idSeg: -1, plSeg: -1}
+				d.entries = append(d.entries, entry)
+			}
+			value, err := poUnquote(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid po at line %d: %w", i+1, err)
+			}
+			field = &poField{keyword: m[1], value: value, start: i, end: i + 1}
+			entry.fields = append(entry.fields, field)
+			switch m[1] {
+			case "msgid":
+				entry.msgid = field
+			case "msgid_plural":
+				entry.plural = field
+			}
+			continue
+		}
+		if m := poStringRe.FindStringSubmatch(line); m != nil && field != nil {
+			value, err := poUnquote(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid po at line %d: %w", i+1, err)
+			}
+			field.value += value
+			field.end = i + 1
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			field = nil
+			if trimmed == "" {
+				entry = nil
+			}
+			continue
+		}
+		return nil, fmt.Errorf("invalid po at line %d: %s", i+1, line)
+	}
+	for _, e := range d.entries {
+		d.collect(e)
+	}
+	return d, nil
+}
+
+func (d *poDoc) collect(e *poEntry) {
+	if e.msgid == nil || e.msgid.value == "" { // 文件头
+		return
+	}
+	for _, f := range e.fields {
+		if strings.HasPrefix(f.keyword, "msgstr") && f.value != "" {
+			return
+		}
+	}
+	e.idSeg = len(d.segments)
+	d.segments = append(d.segments, e.msgid.value)
+	if e.plural != nil {
+		e.plSeg = len(d.segments)
+		d.segments = append(d.segments, e.plural.value)
+	}
+}
+
+func (d *poDoc) Segments() []string {
+	return d.segments
+}
+
+func (d *poDoc) Render(translated []string) (string, error) {
+	if len(translated) != len(d.segments) {
+		return "", fmt.Errorf("translated %d segments, want %d", len(translated), len(d.segments))
+	}
+	replaced := make(map[int]*poField) // 起始行 -> 需要替换的msgstr
+	values := make(map[*poField]string)
+	for _, e := range d.entries {
+		if e.idSeg < 0 {
+			continue
+		}
+		for _, f := range e.fields {
+			if !strings.HasPrefix(f.keyword, "msgstr") {
+				continue
+			}
+			replaced[f.start] = f
+			if f.keyword == "msgstr" || f.keyword == "msgstr[0]" || e.plSeg < 0 { // msgstr[0]为单数形式
+				values[f] = translated[e.idSeg]
+			} else {
+				values[f] = translated[e.plSeg]
+			}
+		}
+	}
+	out := make([]string, 0, len(d.lines))
+	for i := 0; i < len(d.lines); i++ {
+		f, ok := replaced[i]
+		if !ok {
+			out = append(out, d.lines[i])
+			continue
+		}
+		out = append(out, poFormat(f.keyword, values[f])...)
+		i = f.end - 1
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// poFormat 多行内容按gettext的习惯写成 msgstr "" 加每行一个字符串
+func poFormat(keyword, value string) []string {
+	if !strings.Contains(strings.TrimSuffix(value, "\n"), "\n") {
+		return []string{keyword + " " + poQuote(value)}
+	}
+	lines := []string{keyword + ` ""`}
+	for _, part := range strings.SplitAfter(value, "\n") {
+		if part != "" {
+			lines = append(lines, poQuote(part))
+		}
+	}
+	return lines
+}
+
+func poQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func poUnquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("bad string %s", s)
+	}
+	s = s[1 : len(s)-1]
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		default: // \" \\ 等直接取转义后的字符
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,32 @@
+package document
+
+import (
+	"regexp"
+	"strings"
+)
+
+var srtBlockRe = regexp.MustCompile(`\n[ \t]*\n`)
+
+// parseSRT 每条字幕的序号和时间轴原样保留，多行字幕作为一个片段翻译
+func parseSRT(content string) Document {
+	t := &template{}
+	content = strings.TrimPrefix(strings.ReplaceAll(content, "\r\n", "\n"), "\uFEFF")
+	blocks := srtBlockRe.Split(strings.Trim(content, "\n"), -1)
+	for i, block := range blocks {
+		if i > 0 {
+			t.literal("\n\n")
+		}
+		lines := strings.SplitN(strings.Trim(block, "\n"), "\n", 3)
+		if len(lines) < 2 || !strings.Contains(lines[1], "-->") {
+			t.literal(block)
+			continue
+		}
+		t.literal(lines[0] + "\n" + lines[1])
+		if len(lines) == 3 {
+			t.literal("\n")
+			t.segment(lines[2])
+		}
+	}
+	t.literal("\n")
+	return t
+}
@@ -0,0 +1,79 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"simpletools/internal/translate"
+	"strings"
+)
+
+// yamlDoc 基于yaml.Node保留key顺序、注释和字符串风格，只翻译字符串值，支持多文档
+type yamlDoc struct {
+	docs     []*yaml.Node
+	nodes    []*yaml.Node // 与segments一一对应
+	segments []string
+}
+
+func parseYAML(content string) (Document, error) {
+	d := &yamlDoc{}
+	dec := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+		d.docs = append(d.docs, &node)
+		d.collect(&node)
+	}
+	return d, nil
+}
+
+func (d *yamlDoc) collect(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			d.collect(child)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 { // 只处理value，key不翻译
+			d.collect(node.Content[i])
+		}
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!str" && translate.Translatable(node.Value) {
+			d.nodes = append(d.nodes, node)
+			d.segments = append(d.segments, node.Value)
+		}
+	}
+}
+
+func (d *yamlDoc) Segments() []string {
+	return d.segments
+}
+
+func (d *yamlDoc) Render(translated []string) (string, error) {
+	if len(translated) != len(d.segments) {
+		return "", fmt.Errorf("translated %d segments, want %d", len(translated), len(d.segments))
+	}
+	for i, node := range d.nodes {
+		node.Value = translated[i]
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, doc := range d.docs {
+		if err := enc.Encode(doc); err != nil {
+			return "", err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
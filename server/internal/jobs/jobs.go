@@ -0,0 +1,372 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"runtime/debug"
+	"simpletools/internal/configs"
+	"simpletools/internal/defs"
+	"simpletools/internal/utils"
+	"simpletools/lib/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued   Status = "queued"
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+const (
+	defaultWorkers      = 2
+	defaultQueueSize    = 100
+	defaultUserPending  = 10
+	defaultKeep         = 3600
+	defaultDrainTimeout = 30
+
+	shutdownCancelWait = 5 * time.Second
+)
+
+// RunFunc 执行任务，params为提交时保存的参数，ctx在任务取消或进程退出时结束
+type RunFunc func(ctx context.Context, job *Job) (any, *defs.CustomError)
+
+var runners = make(map[string]RunFunc)
+
+// Register 注册任务类型，在init中调用；持久化的任务重启后按类型找到执行函数
+func Register(kind string, run RunFunc) {
+	runners[kind] = run
+}
+
+// Job 一个异步任务，导出字段用于查询和持久化，修改时持有Manager的锁
+type Job struct {
+	Id         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Username   string          `json:"username"`
+	Platform   string          `json:"platform"`
+	Client     string          `json:"client,omitempty"` // 提交请求的来源，未登录时用于区分任务归属和统计用量
+	Params     json.RawMessage `json:"params,omitempty"`
+	Status     Status          `json:"status"`
+	Code       int             `json:"code"`
+	Error      string          `json:"error,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	CreatedAt  int64           `json:"created_at"`
+	StartedAt  int64           `json:"started_at"`
+	FinishedAt int64           `json:"finished_at"`
+
+	cancel   context.CancelFunc
+	canceled bool // 执行中被用户取消
+}
+
+// owned 登录用户按用户名和平台区分，未登录的调用方按来源区分
+func (j *Job) owned(username, platform, client string) bool {
+	if username == "" {
+		return j.Username == "" && j.Client == client
+	}
+	return j.Username == username && j.Platform == platform
+}
+
+func (j *Job) finished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// View 返回给客户端的任务信息，不包含参数
+type View struct {
+	Id         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Status     Status          `json:"status"`
+	Code       int             `json:"code"`
+	Error      string          `json:"error,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	CreatedAt  int64           `json:"created_at"`
+	StartedAt  int64           `json:"started_at"`
+	FinishedAt int64           `json:"finished_at"`
+}
+
+// Manager 任务表和执行任务的协程，任务经EventSink主循环分发到队列
+type Manager struct {
+	cfg     configs.JobConfig
+	log     *logger.CustomLogger
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	queue   chan *Job
+	ctx     context.Context // 进程退出时取消所有执行中的任务
+	cancel  context.CancelFunc
+	closing bool // 正在退出，不再开始新的任务
+	running sync.WaitGroup
+}
+
+func NewManager(cfg configs.JobConfig, log *logger.CustomLogger) *Manager {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.UserPending <= 0 {
+		cfg.UserPending = defaultUserPending
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = defaultKeep
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = defaultDrainTimeout
+	}
+	m := &Manager{cfg: cfg, log: log, jobs: make(map[string]*Job), queue: make(chan *Job, cfg.QueueSize)}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	for i := 0; i < cfg.Workers; i++ {
+		go m.work()
+	}
+	return m
+}
+
+func newId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Create 登记一个排队中的任务，之后需要交给Dispatch才会执行
+func (m *Manager) Create(kind, username, platform, client string, params any) (*Job, error) {
+	if _, ok := runners[kind]; !ok {
+		return nil, fmt.Errorf("unknown job kind:%s", kind)
+	}
+	bs, err := jsoniter.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := 0
+	for _, j := range m.jobs {
+		if !j.finished() && j.owned(username, platform, client) {
+			pending++
+		}
+	}
+	if pending >= m.cfg.UserPending {
+		return nil, fmt.Errorf("too many pending jobs, max %d", m.cfg.UserPending)
+	}
+	job := &Job{Id: newId(), Kind: kind, Username: username, Platform: platform, Client: client, Params: bs, Status: StatusQueued, CreatedAt: time.Now().Unix()}
+	m.jobs[job.Id] = job
+	return job, nil
+}
+
+// Dispatch 由EventSink在主循环中调用，队列满时任务直接失败，不阻塞主循环
+func (m *Manager) Dispatch(job *Job) {
+	select {
+	case m.queue <- job:
+	default:
+		m.finish(job, nil, defs.NewCustomError(defs.ErrCodeSystemError, fmt.Errorf("job queue is full")))
+	}
+}
+
+// Remove 删除还没有交给Dispatch的任务，提交失败时撤销Create
+func (m *Manager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok && job.Status == StatusQueued {
+		delete(m.jobs, id)
+	}
+}
+
+// Get 只能查询自己提交的任务
+func (m *Manager) Get(id, username, platform, client string) (View, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || !job.owned(username, platform, client) {
+		return View{}, false
+	}
+	return View{Id: job.Id, Kind: job.Kind, Status: job.Status, Code: job.Code, Error: job.Error, Result: job.Result,
+		CreatedAt: job.CreatedAt, StartedAt: job.StartedAt, FinishedAt: job.FinishedAt}, true
+}
+
+// Cancel 排队中的任务直接取消，执行中的任务通知其退出，已结束的任务返回false
+func (m *Manager) Cancel(id, username, platform, client string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || !job.owned(username, platform, client) {
+		return false, fmt.Errorf("job not found:%s", id)
+	}
+	switch job.Status {
+	case StatusQueued:
+		job.Status, job.FinishedAt = StatusCanceled, time.Now().Unix()
+	case StatusRunning:
+		job.canceled = true
+		job.cancel()
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (m *Manager) work() {
+	for job := range m.queue {
+		m.run(job)
+	}
+}
+
+func (m *Manager) run(job *Job) {
+	m.mu.Lock()
+	if job.Status != StatusQueued || m.closing { // 排队时已被取消，或进程正在退出
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	job.Status, job.StartedAt, job.cancel = StatusRunning, time.Now().Unix(), cancel
+	m.running.Add(1)
+	m.mu.Unlock()
+	defer m.running.Done()
+
+	var result any
+	var cErr *defs.CustomError
+	func() {
+		defer func() {
+			if err := recover(); err != nil {
+				m.log.Error().Str("id", job.Id).Str("kind", job.Kind).Msgf("job panic: %v|%s", err, string(debug.Stack()))
+				cErr = defs.NewCustomError(defs.ErrCodeSystemPanic, fmt.Errorf("job panic: %v", err))
+			}
+		}()
+		result, cErr = runners[job.Kind](ctx, job)
+	}()
+	m.finish(job, result, cErr)
+}
+
+// finish 记录任务结果，取消导致的失败记为canceled；进程退出导致的中断重新排队，由Shutdown保存
+func (m *Manager) finish(job *Job, result any, cErr *defs.CustomError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case job.canceled:
+		job.Status = StatusCanceled
+	case job.Status == StatusRunning && m.ctx.Err() != nil:
+		job.Status, job.StartedAt, job.cancel = StatusQueued, 0, nil
+		return
+	case cErr != nil:
+		job.Status, job.Code, job.Error = StatusFailed, cErr.GetCode(), cErr.GetErr()
+	default:
+		bs, err := jsoniter.Marshal(result)
+		if err != nil {
+			job.Status, job.Code, job.Error = StatusFailed, int(defs.ErrCodeSystemError), err.Error()
+		} else {
+			job.Status, job.Result = StatusDone, bs
+		}
+	}
+	job.FinishedAt, job.cancel, job.Params = time.Now().Unix(), nil, nil
+	m.log.Info().Str("id", job.Id).Str("kind", job.Kind).Str("status", string(job.Status)).Str("username", job.Username).
+		Str("platform", job.Platform).Int64("cost", job.FinishedAt-job.CreatedAt).Msg("job finished")
+}
+
+// Clean 删除结束超过keep秒的任务
+func (m *Manager) Clean(now int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, job := range m.jobs {
+		if job.finished() && now-job.FinishedAt > int64(m.cfg.Keep) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// Shutdown 正常退出时等待执行中的任务最多drain_timeout秒，超时的任务取消后重新排队，再保存未完成的任务和结果；
+// 其他退出方式直接取消执行中的任务，不保存
+func (m *Manager) Shutdown(mode defs.ExitMode) {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+	if mode != defs.ExitModeSaveAndExit {
+		m.cancel()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Duration(m.cfg.DrainTimeout) * time.Second):
+		m.log.Warn().Int("drain_timeout", m.cfg.DrainTimeout).Msg("jobs drain timeout, cancel running jobs")
+		m.cancel()
+		select { // 执行函数都会响应ctx，这里只是防止个别任务无法退出导致进程卡住
+		case <-done:
+		case <-time.After(shutdownCancelWait):
+		}
+	}
+	m.cancel()
+	if err := m.save(); err != nil {
+		m.log.Error().Err(err).Msg("save jobs failed")
+	}
+}
+
+// save 保存所有任务
+func (m *Manager) save() error {
+	if m.cfg.PersistPath == "" {
+		return nil
+	}
+	m.mu.Lock()
+	list := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		list = append(list, job)
+	}
+	m.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt < list[j].CreatedAt
+	})
+	bs, err := jsoniter.Marshal(list)
+	if err != nil {
+		return err
+	}
+	m.log.Info().Int("count", len(list)).Str("path", m.cfg.PersistPath).Msg("save jobs")
+	return utils.WriteFileAtomic(m.cfg.PersistPath, bs, 0600)
+}
+
+// Load 读取上次退出时保存的任务，返回需要重新分发的排队任务
+func (m *Manager) Load() ([]*Job, error) {
+	if m.cfg.PersistPath == "" {
+		return nil, nil
+	}
+	bs, err := os.ReadFile(m.cfg.PersistPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Job
+	if err = jsoniter.Unmarshal(bs, &list); err != nil {
+		return nil, fmt.Errorf("jobs file %s: %w", m.cfg.PersistPath, err)
+	}
+	now := time.Now().Unix()
+	var queued []*Job
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range list {
+		if job.finished() && now-job.FinishedAt > int64(m.cfg.Keep) {
+			continue
+		}
+		if !job.finished() {
+			if _, ok := runners[job.Kind]; !ok {
+				job.Status, job.Code, job.Error, job.FinishedAt = StatusFailed, int(defs.ErrCodeSystemError), "unknown job kind", now
+			} else {
+				job.Status = StatusQueued
+				queued = append(queued, job)
+			}
+		}
+		m.jobs[job.Id] = job
+	}
+	return queued, nil
+}
@@ -0,0 +1,297 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"simpletools/internal/configs"
+	"simpletools/internal/defs"
+	"simpletools/lib/logger"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	kindOk    = "test.ok"
+	kindFail  = "test.fail"
+	kindBlock = "test.block"
+)
+
+// blockRelease 设置并关闭后kindBlock的任务正常结束，否则一直等到ctx结束
+var blockRelease atomic.Pointer[chan struct{}]
+
+func init() {
+	Register(kindOk, func(ctx context.Context, job *Job) (any, *defs.CustomError) {
+		return map[string]string{"params": string(job.Params)}, nil
+	})
+	Register(kindFail, func(ctx context.Context, job *Job) (any, *defs.CustomError) {
+		return nil, defs.NewCustomError(defs.ErrCodeLLMUpstreamError, errors.New("upstream failed"))
+	})
+	Register(kindBlock, func(ctx context.Context, job *Job) (any, *defs.CustomError) {
+		var release chan struct{}
+		if p := blockRelease.Load(); p != nil {
+			release = *p
+		}
+		select {
+		case <-ctx.Done():
+			return nil, defs.NewCustomError(defs.ErrCodeSystemError, ctx.Err())
+		case <-release:
+			return "released", nil
+		}
+	})
+}
+
+func newTestManager(t *testing.T, cfg configs.JobConfig) *Manager {
+	t.Helper()
+	m := NewManager(cfg, &logger.CustomLogger{Logger: zerolog.Nop()})
+	t.Cleanup(func() { m.Shutdown(defs.ExitModeKillNoWait) })
+	return m
+}
+
+func mustCreate(t *testing.T, m *Manager, kind, username, client string) *Job {
+	t.Helper()
+	job, err := m.Create(kind, username, "web", client, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// waitStatus 等待任务进入指定状态
+func waitStatus(t *testing.T, m *Manager, job *Job, want Status) View {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		view, ok := m.Get(job.Id, job.Username, job.Platform, job.Client)
+		if ok && view.Status == want {
+			return view
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s status %s, want %s", job.Id, view.Status, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStatusTransitions(t *testing.T) {
+	m := newTestManager(t, configs.JobConfig{})
+
+	ok := mustCreate(t, m, kindOk, "alice", "")
+	if view, _ := m.Get(ok.Id, "alice", "web", ""); view.Status != StatusQueued {
+		t.Fatalf("status %s after create, want queued", view.Status)
+	}
+	m.Dispatch(ok)
+	view := waitStatus(t, m, ok, StatusDone)
+	if string(view.Result) != `{"params":"\"p\""}` || view.FinishedAt == 0 || view.StartedAt == 0 {
+		t.Fatalf("done view %+v", view)
+	}
+
+	fail := mustCreate(t, m, kindFail, "alice", "")
+	m.Dispatch(fail)
+	view = waitStatus(t, m, fail, StatusFailed)
+	if view.Code != int(defs.ErrCodeLLMUpstreamError) || view.Error != "upstream failed" {
+		t.Fatalf("failed view %+v", view)
+	}
+
+	if _, err := m.Create("test.unknown", "alice", "web", "", nil); err == nil {
+		t.Fatal("create unknown kind succeeded")
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	m := newTestManager(t, configs.JobConfig{UserPending: 1})
+	cases := []struct {
+		name     string
+		job      *Job
+		username string
+		platform string
+		client   string
+		ok       bool
+	}{
+		{"same user", mustCreate(t, m, kindOk, "alice", "c1"), "alice", "web", "c2", true},
+		{"other user", mustCreate(t, m, kindOk, "bob", "c1"), "alice", "web", "c1", false},
+		{"same user other platform", mustCreate(t, m, kindOk, "carol", "c1"), "carol", "ios", "c1", false},
+		{"anonymous same client", mustCreate(t, m, kindOk, "", "ip:1.1.1.1"), "", "", "ip:1.1.1.1", true},
+		{"anonymous other client", mustCreate(t, m, kindOk, "", "ip:2.2.2.2"), "", "", "ip:3.3.3.3", false},
+		{"anonymous client of a user job", mustCreate(t, m, kindOk, "dave", "ip:4.4.4.4"), "", "", "ip:4.4.4.4", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, ok := m.Get(tc.job.Id, tc.username, tc.platform, tc.client); ok != tc.ok {
+				t.Fatalf("get ok:%v, want %v", ok, tc.ok)
+			}
+			if _, err := m.Cancel(tc.job.Id, tc.username, tc.platform, tc.client); (err == nil) != tc.ok {
+				t.Fatalf("cancel err:%v, want ok:%v", err, tc.ok)
+			}
+		})
+	}
+
+	// 排队数量按归属计算，不同来源的匿名调用方互不影响
+	if _, err := m.Create(kindOk, "", "", "ip:2.2.2.2", nil); err == nil {
+		t.Fatal("pending limit not applied to anonymous client")
+	}
+	if _, err := m.Create(kindOk, "", "", "ip:5.5.5.5", nil); err != nil {
+		t.Fatalf("pending limit shared between anonymous clients: %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := newTestManager(t, configs.JobConfig{Workers: 1})
+
+	queued := mustCreate(t, m, kindOk, "alice", "")
+	if ok, err := m.Cancel(queued.Id, "alice", "web", ""); !ok || err != nil {
+		t.Fatalf("cancel queued ok:%v err:%v", ok, err)
+	}
+	m.Dispatch(queued) // 已取消的任务出队后不再执行
+	running := mustCreate(t, m, kindBlock, "alice", "")
+	m.Dispatch(running)
+	waitStatus(t, m, running, StatusRunning)
+	if view, _ := m.Get(queued.Id, "alice", "web", ""); view.Status != StatusCanceled || view.StartedAt != 0 {
+		t.Fatalf("canceled queued job %+v", view)
+	}
+
+	if ok, err := m.Cancel(running.Id, "alice", "web", ""); !ok || err != nil {
+		t.Fatalf("cancel running ok:%v err:%v", ok, err)
+	}
+	waitStatus(t, m, running, StatusCanceled)
+	if ok, err := m.Cancel(running.Id, "alice", "web", ""); ok || err != nil {
+		t.Fatalf("cancel finished ok:%v err:%v", ok, err)
+	}
+}
+
+func TestDispatchQueueFull(t *testing.T) {
+	m := newTestManager(t, configs.JobConfig{Workers: 1, QueueSize: 1})
+	running := mustCreate(t, m, kindBlock, "alice", "")
+	m.Dispatch(running)
+	waitStatus(t, m, running, StatusRunning)
+
+	queued := mustCreate(t, m, kindOk, "alice", "")
+	m.Dispatch(queued)
+	overflow := mustCreate(t, m, kindOk, "alice", "")
+	m.Dispatch(overflow)
+	view := waitStatus(t, m, overflow, StatusFailed)
+	if view.Code != int(defs.ErrCodeSystemError) || !strings.Contains(view.Error, "queue is full") {
+		t.Fatalf("overflow view %+v", view)
+	}
+	if view, _ := m.Get(queued.Id, "alice", "web", ""); view.Status != StatusQueued {
+		t.Fatalf("queued job status %s", view.Status)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	cases := []struct {
+		name  string
+		mode  defs.ExitMode
+		saved bool
+	}{
+		{"save and exit", defs.ExitModeSaveAndExit, true},
+		{"kill", defs.ExitModeKillNoWait, false},
+		{"panic", defs.ExitModePanicNoWait, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jobs.json")
+			m := newTestManager(t, configs.JobConfig{Workers: 1, DrainTimeout: 1, PersistPath: path})
+			running := mustCreate(t, m, kindBlock, "alice", "")
+			m.Dispatch(running)
+			waitStatus(t, m, running, StatusRunning)
+			queued := mustCreate(t, m, kindOk, "", "ip:1.1.1.1")
+
+			start := time.Now()
+			m.Shutdown(tc.mode)
+			if tc.mode == defs.ExitModeSaveAndExit && time.Since(start) < time.Second {
+				t.Fatalf("shutdown returned after %s, before drain_timeout", time.Since(start))
+			}
+			// 被中断的任务重新排队
+			waitStatus(t, m, running, StatusQueued)
+			if _, err := os.Stat(path); (err == nil) != tc.saved {
+				t.Fatalf("jobs file exists:%v, want %v", err == nil, tc.saved)
+			}
+			if !tc.saved {
+				return
+			}
+
+			loaded := newTestManager(t, configs.JobConfig{PersistPath: path})
+			list, err := loaded.Load()
+			if err != nil || len(list) != 2 {
+				t.Fatalf("load %d jobs err:%v", len(list), err)
+			}
+			if _, ok := loaded.Get(queued.Id, "", "", "ip:1.1.1.1"); !ok {
+				t.Fatal("anonymous job lost its client after reload")
+			}
+		})
+	}
+}
+
+func TestDrainWithinTimeout(t *testing.T) {
+	release := make(chan struct{})
+	blockRelease.Store(&release)
+	defer blockRelease.Store(nil)
+	m := newTestManager(t, configs.JobConfig{Workers: 1, DrainTimeout: 5})
+	running := mustCreate(t, m, kindBlock, "alice", "")
+	m.Dispatch(running)
+	waitStatus(t, m, running, StatusRunning)
+
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	m.Shutdown(defs.ExitModeSaveAndExit)
+	if view, _ := m.Get(running.Id, "alice", "web", ""); view.Status != StatusDone {
+		t.Fatalf("status %s after drain, want done", view.Status)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	now := time.Now().Unix()
+	m := newTestManager(t, configs.JobConfig{Keep: 60, PersistPath: path})
+	m.jobs = map[string]*Job{
+		"done":    {Id: "done", Kind: kindOk, Username: "alice", Platform: "web", Status: StatusDone, Result: []byte(`1`), CreatedAt: now - 2, FinishedAt: now - 1},
+		"expired": {Id: "expired", Kind: kindOk, Username: "alice", Platform: "web", Status: StatusDone, CreatedAt: now - 200, FinishedAt: now - 100},
+		"queued":  {Id: "queued", Kind: kindOk, Username: "alice", Platform: "web", Params: []byte(`"p"`), Status: StatusQueued, CreatedAt: now},
+		"running": {Id: "running", Kind: kindOk, Username: "alice", Platform: "web", Status: StatusRunning, CreatedAt: now, StartedAt: now},
+		"unknown": {Id: "unknown", Kind: "test.removed", Username: "alice", Platform: "web", Status: StatusQueued, CreatedAt: now},
+	}
+	if err := m.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newTestManager(t, configs.JobConfig{Keep: 60, PersistPath: path})
+	queued, err := loaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, job := range queued {
+		ids[job.Id] = true
+		if job.Status != StatusQueued {
+			t.Fatalf("requeued job %s status %s", job.Id, job.Status)
+		}
+	}
+	if len(ids) != 2 || !ids["queued"] || !ids["running"] {
+		t.Fatalf("requeued %v, want queued and running", ids)
+	}
+	cases := []struct {
+		id     string
+		status Status
+		found  bool
+	}{
+		{"done", StatusDone, true},
+		{"expired", "", false},
+		{"unknown", StatusFailed, true},
+	}
+	for _, tc := range cases {
+		view, ok := loaded.Get(tc.id, "alice", "web", "")
+		if ok != tc.found || view.Status != tc.status {
+			t.Fatalf("job %s found:%v view:%+v", tc.id, ok, view)
+		}
+	}
+	if view, _ := loaded.Get("done", "alice", "web", ""); string(view.Result) != "1" {
+		t.Fatalf("result %s not restored", view.Result)
+	}
+	if loaded.jobs["queued"].Params == nil {
+		t.Fatal("params of queued job not restored")
+	}
+}
@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"simpletools/internal/configs"
+	"simpletools/internal/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL       = 24 * time.Hour
+	defaultCacheMaxMemory = 64  // MB
+	cacheEntryOverhead    = 128 // 估算的单条记录额外内存开销
+	mb                    = 1024 * 1024
+)
+
+type cacheEntry struct {
+	Key      string        `json:"key"`
+	Resp     *ChatResponse `json:"resp"`
+	ExpireAt int64         `json:"expire_at"` // 过期时间戳，秒
+	size     int
+}
+
+// Cache 以 提供方+模型+全部消息 为key的内容寻址缓存，LRU淘汰，按估算内存上限控制容量
+type Cache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	maxMemory int
+	used      int
+	lru       *list.List // 头部最近使用
+	entries   map[string]*list.Element
+}
+
+func NewCache(cfg configs.LLMCacheConfig) *Cache {
+	c := &Cache{
+		ttl:       defaultCacheTTL,
+		maxMemory: defaultCacheMaxMemory * mb,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+	if cfg.TTL > 0 {
+		c.ttl = time.Duration(cfg.TTL) * time.Second
+	}
+	if cfg.MaxMemory > 0 {
+		c.maxMemory = cfg.MaxMemory * mb
+	}
+	return c
+}
+
+// CacheKey 计算请求的缓存key，任一消息不同都视为不同的请求
+func CacheKey(provider, model string, messages []Message) string {
+	var sb strings.Builder
+	sb.WriteString(provider)
+	sb.WriteByte(0)
+	sb.WriteString(model)
+	for _, msg := range messages {
+		sb.WriteByte(0)
+		sb.WriteString(msg.Role)
+		sb.WriteByte(0)
+		sb.WriteString(msg.Content)
+	}
+	return utils.SHA256([]byte(sb.String()))
+}
+
+func entrySize(key string, resp *ChatResponse) int {
+	return len(key) + len(resp.Content) + len(resp.Model) + len(resp.FinishReason) + cacheEntryOverhead
+}
+
+func (c *Cache) Get(key string) (*ChatResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().Unix() >= entry.ExpireAt {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	resp := *entry.Resp
+	return &resp, true
+}
+
+func (c *Cache) Set(key string, resp *ChatResponse) {
+	cp := *resp
+	cp.Cached = false
+	c.set(&cacheEntry{Key: key, Resp: &cp, ExpireAt: time.Now().Add(c.ttl).Unix()})
+}
+
+func (c *Cache) set(entry *cacheEntry) {
+	entry.size = entrySize(entry.Key, entry.Resp)
+	if entry.size > c.maxMemory {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[entry.Key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[entry.Key] = c.lru.PushFront(entry)
+	c.used += entry.size
+	for c.used > c.maxMemory {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.Key)
+	c.used -= entry.size
+}
+
+// Save 把未过期的记录按从旧到新的顺序写入文件，中途退出不会损坏旧文件
+func (c *Cache) Save(path string) error {
+	var buf bytes.Buffer
+	now := time.Now().Unix()
+
+	c.mu.Lock()
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*cacheEntry)
+		if now >= entry.ExpireAt {
+			continue
+		}
+		bs, err := jsoniter.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		buf.Write(bs)
+		buf.WriteByte('\n')
+	}
+	c.mu.Unlock()
+	return utils.WriteFileAtomic(path, buf.Bytes(), 0600)
+}
+
+// Load 从文件恢复记录，文件不存在不算错误
+func (c *Cache) Load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := time.Now().Unix()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*mb)
+	for scanner.Scan() {
+		entry := &cacheEntry{}
+		if err = jsoniter.Unmarshal(scanner.Bytes(), entry); err != nil || entry.Resp == nil {
+			continue
+		}
+		if now >= entry.ExpireAt {
+			continue
+		}
+		c.set(entry)
+	}
+	return scanner.Err()
+}
+
+// cachedProvider 在提供方前加一层缓存，ChatRequest.NoCache为true时跳过读缓存但仍会写入新结果
+type cachedProvider struct {
+	Provider
+	cache *Cache
+}
+
+func (p *cachedProvider) key(req *ChatRequest) string {
+	model := req.Model
+	if model == "" {
+		model = p.Model()
+	}
+	return CacheKey(p.Name(), model, req.Messages)
+}
+
+func (p *cachedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	key := p.key(req)
+	if !req.NoCache {
+		if resp, ok := p.cache.Get(key); ok {
+			resp.Cached, resp.Usage = true, Usage{}
+			return resp, nil
+		}
+	}
+	resp, err := p.Provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.cache.Set(key, resp)
+	return resp, nil
+}
+
+func (p *cachedProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error) {
+	key := p.key(req)
+	if !req.NoCache {
+		if resp, ok := p.cache.Get(key); ok { // 命中时一次性输出完整内容
+			if err := onDelta(resp.Content); err != nil {
+				return nil, err
+			}
+			resp.Cached, resp.Usage = true, Usage{}
+			return resp, nil
+		}
+	}
+	resp, err := p.Provider.ChatStream(ctx, req, onDelta)
+	if err != nil {
+		return nil, err
+	}
+	p.cache.Set(key, resp)
+	return resp, nil
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simpletools/internal/configs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testResp(content string) *ChatResponse {
+	return &ChatResponse{Content: content, Model: "m", FinishReason: "stop", Usage: Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7}}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := NewCache(configs.LLMCacheConfig{TTL: 60})
+	now := time.Now().Unix()
+	c.Set("live", testResp("a"))
+	c.set(&cacheEntry{Key: "expired", Resp: testResp("b"), ExpireAt: now})
+
+	if resp, ok := c.Get("live"); !ok || resp.Content != "a" || resp.Cached {
+		t.Fatalf("get live %+v ok:%v", resp, ok)
+	}
+	if _, ok := c.Get("expired"); ok {
+		t.Fatal("expired entry returned")
+	}
+	if _, ok := c.entries["expired"]; ok {
+		t.Fatal("expired entry not removed on get")
+	}
+	if c.used != entrySize("live", testResp("a")) {
+		t.Fatalf("used %d after removing expired entry", c.used)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	size := entrySize("k0", testResp("x"))
+	c := NewCache(configs.LLMCacheConfig{})
+	c.maxMemory = 3 * size
+	for i := 0; i < 3; i++ {
+		c.Set(fmt.Sprintf("k%d", i), testResp("x"))
+	}
+	c.Get("k0") // k0变为最近使用，k1最先被淘汰
+	c.Set("k3", testResp("x"))
+
+	cases := []struct {
+		key string
+		ok  bool
+	}{{"k0", true}, {"k1", false}, {"k2", true}, {"k3", true}}
+	for _, tc := range cases {
+		if _, ok := c.Get(tc.key); ok != tc.ok {
+			t.Fatalf("key %s cached:%v, want %v", tc.key, ok, tc.ok)
+		}
+	}
+	if c.used != 3*size {
+		t.Fatalf("used %d, want %d", c.used, 3*size)
+	}
+
+	// 单条超过上限的记录不缓存，也不挤掉已有记录
+	c.Set("big", testResp(strings.Repeat("x", 4*size)))
+	if _, ok := c.Get("big"); ok || c.lru.Len() != 3 {
+		t.Fatalf("oversized entry cached, len %d", c.lru.Len())
+	}
+}
+
+func TestCachePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "llm_cache.jsonl")
+	c := NewCache(configs.LLMCacheConfig{TTL: 60})
+	c.Set("old", testResp("1"))
+	c.Set("new", testResp("2"))
+	c.set(&cacheEntry{Key: "expired", Resp: testResp("3"), ExpireAt: time.Now().Unix() - 1})
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"old"`) || !strings.Contains(lines[1], `"new"`) {
+		t.Fatalf("saved lines %q, want old then new without expired", lines)
+	}
+
+	// 损坏的行跳过，不影响其他记录
+	if err = os.WriteFile(path, append(bs, []byte("not json\n")...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewCache(configs.LLMCacheConfig{TTL: 60})
+	if err = loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.lru.Front().Value.(*cacheEntry).Key != "new" {
+		t.Fatal("recency order not restored")
+	}
+	for key, content := range map[string]string{"old": "1", "new": "2"} {
+		resp, ok := loaded.Get(key)
+		if !ok || resp.Content != content || resp.Usage.TotalTokens != 7 {
+			t.Fatalf("loaded %s %+v ok:%v", key, resp, ok)
+		}
+	}
+	if err = NewCache(configs.LLMCacheConfig{}).Load(filepath.Join(t.TempDir(), "missing.jsonl")); err != nil {
+		t.Fatalf("load missing file: %v", err)
+	}
+}
+
+// countProvider 记录请求次数，每次返回固定内容
+type countProvider struct {
+	calls int
+}
+
+func (p *countProvider) Name() string  { return "count" }
+func (p *countProvider) Model() string { return "m" }
+
+func (p *countProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	return testResp("answer"), nil
+}
+
+func (p *countProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error) {
+	p.calls++
+	if err := onDelta("answer"); err != nil {
+		return nil, err
+	}
+	return testResp("answer"), nil
+}
+
+func TestCachedProvider(t *testing.T) {
+	upstream := &countProvider{}
+	p := &cachedProvider{Provider: upstream, cache: NewCache(configs.LLMCacheConfig{})}
+	req := NewChatRequest("system", "user", false)
+
+	first, err := p.Chat(context.Background(), req)
+	if err != nil || first.Cached || first.Usage.TotalTokens != 7 {
+		t.Fatalf("first %+v err:%v", first, err)
+	}
+	// 命中缓存时不请求提供方，用量为0
+	hit, err := p.Chat(context.Background(), req)
+	if err != nil || !hit.Cached || hit.Usage != (Usage{}) || hit.Content != "answer" || upstream.calls != 1 {
+		t.Fatalf("hit %+v err:%v calls:%d", hit, err, upstream.calls)
+	}
+	var streamed string
+	hit, err = p.ChatStream(context.Background(), req, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil || !hit.Cached || hit.Usage != (Usage{}) || streamed != "answer" || upstream.calls != 1 {
+		t.Fatalf("stream hit %+v streamed:%q err:%v calls:%d", hit, streamed, err, upstream.calls)
+	}
+	// 缓存中保存的仍是原始用量
+	if resp, _ := p.cache.Get(p.key(req)); resp.Usage.TotalTokens != 7 {
+		t.Fatalf("cached usage %+v", resp.Usage)
+	}
+
+	req.NoCache = true
+	if resp, _ := p.Chat(context.Background(), req); resp.Cached || upstream.calls != 2 {
+		t.Fatalf("no_cache %+v calls:%d", resp, upstream.calls)
+	}
+}
@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"simpletools/internal/configs"
+	"simpletools/internal/defs"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTimeout    = 120 * time.Second
+	defaultMaxRetries = 2
+	retryBackoff      = 500 * time.Millisecond
+	retryBackoffMax   = 8 * time.Second
+	errBodyLimit      = 64 * 1024
+)
+
+// Client 大模型接口专用http客户端，整次请求(含重试和流式读取)受timeout约束，429/5xx按指数退避重试
+type Client struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	maxRetries int
+}
+
+func NewClient(cfg configs.LLMProviderConfig) *Client {
+	c := &Client{
+		httpClient: &http.Client{}, // 不设置Client.Timeout，否则会截断流式响应，超时统一由context控制
+		timeout:    defaultTimeout,
+		maxRetries: defaultMaxRetries,
+	}
+	if cfg.Timeout > 0 {
+		c.timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if cfg.MaxRetries > 0 {
+		c.maxRetries = cfg.MaxRetries
+	} else if cfg.MaxRetries < 0 {
+		c.maxRetries = 0
+	}
+	return c
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// retryDelay 优先使用上游返回的Retry-After，否则指数退避
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && sec > 0 {
+			return min(time.Duration(sec)*time.Second, retryBackoffMax)
+		}
+	}
+	return min(retryBackoff<<attempt, retryBackoffMax)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do 返回状态码为200的响应，调用方负责关闭Body
+func (c *Client) do(ctx context.Context, url string, body []byte, headers map[string]string) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			lastErr = wrapTransportError(err)
+			if ctx.Err() != nil {
+				return nil, lastErr
+			}
+		} else if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		} else {
+			errBody, _ := io.ReadAll(io.LimitReader(resp.Body, errBodyLimit))
+			_ = resp.Body.Close()
+			statusErr := decodeStatusError(resp.StatusCode, errBody)
+			if !retryable(resp.StatusCode) || statusErr.Code == defs.ErrCodeLLMQuotaExceeded { // 余额不足重试也没有意义
+				return nil, statusErr
+			}
+			lastErr = statusErr
+		}
+		if attempt == c.maxRetries {
+			break
+		}
+		if err = sleepCtx(ctx, retryDelay(attempt, resp)); err != nil {
+			return nil, wrapTransportError(err)
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *Client) Post(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.do(ctx, url, body, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	return respBody, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// PostStream 返回的Body在关闭前一直受timeout约束，调用方必须关闭
+func (c *Client) PostStream(ctx context.Context, url string, body []byte, headers map[string]string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	resp, err := c.do(ctx, url, body, headers)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &cancelBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
@@ -0,0 +1,261 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"simpletools/internal/configs"
+	"simpletools/internal/defs"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stubReply struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+// stubServer 按顺序返回replies，超出后重复最后一个，返回服务和请求计数
+func stubServer(t *testing.T, replies ...stubReply) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		reply := replies[min(n, len(replies)-1)]
+		if got := r.Header.Get("Authorization"); got != "Bearer k" {
+			t.Errorf("request %d authorization %q", n, got)
+		}
+		if body, _ := io.ReadAll(r.Body); string(body) != "req" { // 重试时请求体需要重新发送
+			t.Errorf("request %d body %q", n, body)
+		}
+		if reply.retryAfter != "" {
+			w.Header().Set("Retry-After", reply.retryAfter)
+		}
+		w.WriteHeader(reply.status)
+		_, _ = w.Write([]byte(reply.body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+var testHeaders = map[string]string{"Authorization": "Bearer k"}
+
+func TestNewClient(t *testing.T) {
+	cases := []struct {
+		cfg     configs.LLMProviderConfig
+		timeout time.Duration
+		retries int
+	}{
+		{configs.LLMProviderConfig{}, defaultTimeout, defaultMaxRetries},
+		{configs.LLMProviderConfig{Timeout: 5, MaxRetries: 4}, 5 * time.Second, 4},
+		{configs.LLMProviderConfig{MaxRetries: -1}, defaultTimeout, 0},
+	}
+	for _, tc := range cases {
+		c := NewClient(tc.cfg)
+		if c.timeout != tc.timeout || c.maxRetries != tc.retries {
+			t.Fatalf("config %+v: timeout %v retries %d", tc.cfg, c.timeout, c.maxRetries)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	cases := []struct {
+		name    string
+		retries int
+		replies []stubReply
+		calls   int32
+		code    defs.ErrCode // 0表示成功
+	}{
+		{"ok", 2, []stubReply{{200, "done", ""}}, 1, 0},
+		{"429 then ok", 2, []stubReply{{429, "", ""}, {200, "done", ""}}, 2, 0},
+		{"5xx then ok", 2, []stubReply{{502, "bad gateway", ""}, {503, "", ""}, {200, "done", ""}}, 3, 0},
+		{"429 exhausted", 1, []stubReply{{429, `{"error":{"message":"slow down","type":"rate_limit"}}`, ""}}, 2, defs.ErrCodeLLMRateLimited},
+		{"5xx exhausted", 1, []stubReply{{500, "oops", ""}}, 2, defs.ErrCodeLLMUpstreamError},
+		{"no retry", 0, []stubReply{{503, "", ""}, {200, "done", ""}}, 1, defs.ErrCodeLLMUpstreamError},
+		{"4xx not retried", 2, []stubReply{{400, `{"error":{"message":"bad request"}}`, ""}, {200, "done", ""}}, 1, defs.ErrCodeLLMUpstreamError},
+		{"429 quota not retried", 2, []stubReply{{429, `{"error":{"message":"You exceeded your quota","type":"insufficient_quota"}}`, ""}, {200, "done", ""}}, 1, defs.ErrCodeLLMQuotaExceeded},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, calls := stubServer(t, tc.replies...)
+			c := &Client{httpClient: srv.Client(), timeout: 10 * time.Second, maxRetries: tc.retries}
+			body, err := c.Post(context.Background(), srv.URL, []byte("req"), testHeaders)
+			if calls.Load() != tc.calls {
+				t.Fatalf("%d calls, want %d", calls.Load(), tc.calls)
+			}
+			if tc.code == 0 {
+				if err != nil || string(body) != "done" {
+					t.Fatalf("body %q err %v", body, err)
+				}
+				return
+			}
+			if ErrCode(err) != tc.code {
+				t.Fatalf("err %v, want code %d", err, tc.code)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	header := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{v}}}
+	}
+	cases := []struct {
+		name    string
+		attempt int
+		resp    *http.Response
+		want    time.Duration
+	}{
+		{"backoff", 0, nil, retryBackoff},
+		{"backoff doubles", 2, nil, 4 * retryBackoff},
+		{"backoff capped", 10, nil, retryBackoffMax},
+		{"retry after", 3, header("1"), time.Second},
+		{"retry after capped", 0, header("3600"), retryBackoffMax},
+		{"retry after zero", 1, header("0"), 2 * retryBackoff},
+		{"retry after http date ignored", 0, header(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)), retryBackoff},
+		{"no header", 1, &http.Response{Header: http.Header{}}, 2 * retryBackoff},
+	}
+	for _, tc := range cases {
+		if got := retryDelay(tc.attempt, tc.resp); got != tc.want {
+			t.Fatalf("%s: delay %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	srv, calls := stubServer(t, stubReply{429, "", "1"}, stubReply{200, "done", ""})
+	c := &Client{httpClient: srv.Client(), timeout: 10 * time.Second, maxRetries: 1}
+	start := time.Now()
+	if _, err := c.Post(context.Background(), srv.URL, []byte("req"), testHeaders); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || calls.Load() != 2 {
+		t.Fatalf("retried after %v with %d calls, want Retry-After of 1s", elapsed, calls.Load())
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	hang := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(hang) })
+
+	// 上游无响应，超过timeout后按超时返回
+	c := &Client{httpClient: slow.Client(), timeout: 100 * time.Millisecond, maxRetries: 2}
+	start := time.Now()
+	_, err := c.Post(context.Background(), slow.URL, []byte("req"), testHeaders)
+	if ErrCode(err) != defs.ErrCodeLLMUpstreamTimeout {
+		t.Fatalf("err %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("returned after %v, timeout covers retries", elapsed)
+	}
+
+	// 退避等待期间超时，不再发起下一次请求
+	srv, calls := stubServer(t, stubReply{503, "", "5"}, stubReply{200, "done", ""})
+	c = &Client{httpClient: srv.Client(), timeout: 200 * time.Millisecond, maxRetries: 2}
+	if _, err = c.Post(context.Background(), srv.URL, []byte("req"), testHeaders); ErrCode(err) != defs.ErrCodeLLMUpstreamTimeout || calls.Load() != 1 {
+		t.Fatalf("err %v after %d calls, want timeout during backoff", err, calls.Load())
+	}
+
+	// 调用方取消不属于上游错误
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	c = &Client{httpClient: slow.Client(), timeout: 10 * time.Second, maxRetries: 2}
+	if _, err = c.Post(ctx, slow.URL, []byte("req"), testHeaders); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err %v, want context.Canceled", err)
+	}
+}
+
+func TestPostStreamDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // 流式响应中途不再输出
+	}))
+	t.Cleanup(srv.Close)
+
+	c := &Client{httpClient: srv.Client(), timeout: 200 * time.Millisecond}
+	body, err := c.PostStream(context.Background(), srv.URL, []byte("req"), testHeaders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	buf := make([]byte, 64)
+	if n, err := body.Read(buf); err != nil || string(buf[:n]) != "data: first\n\n" {
+		t.Fatalf("first read %q err %v", buf[:n], err)
+	}
+	// timeout覆盖流式读取，读取在超时后失败而不是一直阻塞
+	start := time.Now()
+	if _, err = io.ReadAll(body); err == nil {
+		t.Fatal("read after timeout succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("read blocked for %v", elapsed)
+	}
+}
+
+func TestDecodeStatusError(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		code    defs.ErrCode
+		typ     string
+		message string
+	}{
+		{"payment required", 402, `{"error":{"message":"Insufficient Balance","type":"unknown_error"}}`, defs.ErrCodeLLMQuotaExceeded, "unknown_error", "Insufficient Balance"},
+		{"insufficient quota type", 429, `{"error":{"message":"quota","type":"insufficient_quota"}}`, defs.ErrCodeLLMQuotaExceeded, "insufficient_quota", "quota"},
+		{"insufficient balance message", 400, `{"error":{"message":"Insufficient balance in account"}}`, defs.ErrCodeLLMQuotaExceeded, "", "Insufficient balance in account"},
+		{"code string used as type", 400, `{"error":{"message":"flagged","code":"content_policy_violation"}}`, defs.ErrCodeLLMContentFiltered, "content_policy_violation", "flagged"},
+		{"numeric code ignored", 400, `{"error":{"message":"bad","code":400}}`, defs.ErrCodeLLMUpstreamError, "", "bad"},
+		{"content filter message", 400, `{"error":{"message":"blocked by content_filter"}}`, defs.ErrCodeLLMContentFiltered, "", "blocked by content_filter"},
+		{"rate limited", 429, `{"error":{"message":"Rate limit reached","type":"requests"}}`, defs.ErrCodeLLMRateLimited, "requests", "Rate limit reached"},
+		{"gateway timeout", 504, "upstream timed out", defs.ErrCodeLLMUpstreamTimeout, "", "upstream timed out"},
+		{"request timeout", 408, "", defs.ErrCodeLLMUpstreamTimeout, "", ""},
+		{"plain text body", 500, " internal error \n", defs.ErrCodeLLMUpstreamError, "", "internal error"},
+		{"json without message", 503, `{"error":{"type":"overloaded"}}`, defs.ErrCodeLLMUpstreamError, "", `{"error":{"type":"overloaded"}}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := decodeStatusError(tc.status, []byte(tc.body))
+			if e.Code != tc.code || e.Status != tc.status || e.Type != tc.typ || e.Message != tc.message {
+				t.Fatalf("got %+v, want code %d type %q message %q", e, tc.code, tc.typ, tc.message)
+			}
+			if ErrCode(e) != tc.code {
+				t.Fatalf("ErrCode %d", ErrCode(e))
+			}
+		})
+	}
+}
+
+func TestErrCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		code defs.ErrCode
+	}{
+		{newError(defs.ErrCodeLLMEmptyAnswer, "empty"), defs.ErrCodeLLMEmptyAnswer},
+		{errors.Join(errors.New("wrapped"), newError(defs.ErrCodeLLMRateLimited, "")), defs.ErrCodeLLMRateLimited},
+		{errors.New("other"), defs.ErrCodeSystemError},
+		{wrapTransportError(context.DeadlineExceeded), defs.ErrCodeLLMUpstreamTimeout},
+		{wrapTransportError(errors.New("connection refused")), defs.ErrCodeLLMUpstreamError},
+		{wrapTransportError(context.Canceled), defs.ErrCodeSystemError},
+	}
+	for i, tc := range cases {
+		if got := ErrCode(tc.err); got != tc.code {
+			t.Fatalf("case %d: ErrCode(%v) = %d, want %d", i, tc.err, got, tc.code)
+		}
+	}
+	if !errors.Is(wrapTransportError(context.Canceled), context.Canceled) {
+		t.Fatal("cancel wrapped as upstream error")
+	}
+}
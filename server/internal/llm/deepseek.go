@@ -0,0 +1,26 @@
+package llm
+
+import "simpletools/internal/configs"
+
+const (
+	deepSeekApiUrl    = "https://api.deepseek.com"
+	deepSeekChatModel = "deepseek-chat"
+)
+
+// DeepSeek 官方接口，协议与OpenAI兼容，未配置的字段使用官方默认值
+type DeepSeek struct {
+	*OpenAICompatible
+}
+
+func NewDeepSeek(cfg configs.LLMProviderConfig) *DeepSeek {
+	if cfg.Name == "" {
+		cfg.Name = ProviderTypeDeepSeek
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = deepSeekApiUrl
+	}
+	if cfg.Model == "" {
+		cfg.Model = deepSeekChatModel
+	}
+	return &DeepSeek{OpenAICompatible: NewOpenAICompatible(cfg)}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"net"
+	"net/http"
+	"simpletools/internal/defs"
+	"strings"
+)
+
+const finishReasonContentFilter = "content_filter"
+
+// Error 上游接口错误，Code用于前端展示对应的提示
+type Error struct {
+	Code    defs.ErrCode
+	Status  int    // http状态码，网络错误时为0
+	Type    string // 上游返回的错误类型
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("llm error code:%d status:%d type:%s msg:%s", e.Code, e.Status, e.Type, e.Message)
+}
+
+func newError(code defs.ErrCode, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+// ErrCode 提取错误对应的错误码，非上游错误统一为系统错误
+func ErrCode(err error) defs.ErrCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return defs.ErrCodeSystemError
+}
+
+type upstreamErrorBody struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"` // 各家实现不一，可能是字符串或数字
+	} `json:"error"`
+}
+
+// decodeStatusError 解析上游非200响应的错误体(OpenAI格式: {"error":{"message","type","code"}})
+func decodeStatusError(status int, body []byte) *Error {
+	e := &Error{Status: status, Message: strings.TrimSpace(string(body))}
+	rlt := &upstreamErrorBody{}
+	if err := jsoniter.Unmarshal(body, rlt); err == nil && rlt.Error.Message != "" {
+		e.Message = rlt.Error.Message
+		e.Type = rlt.Error.Type
+		if code, ok := rlt.Error.Code.(string); ok && e.Type == "" {
+			e.Type = code
+		}
+	}
+	kind := strings.ToLower(e.Type + " " + e.Message)
+	switch {
+	case status == http.StatusPaymentRequired || strings.Contains(kind, "insufficient_quota") || strings.Contains(kind, "insufficient balance"):
+		e.Code = defs.ErrCodeLLMQuotaExceeded
+	case strings.Contains(kind, finishReasonContentFilter) || strings.Contains(kind, "content_policy"):
+		e.Code = defs.ErrCodeLLMContentFiltered
+	case status == http.StatusTooManyRequests:
+		e.Code = defs.ErrCodeLLMRateLimited
+	case status == http.StatusGatewayTimeout || status == http.StatusRequestTimeout:
+		e.Code = defs.ErrCodeLLMUpstreamTimeout
+	default:
+		e.Code = defs.ErrCodeLLMUpstreamError
+	}
+	return e
+}
+
+// wrapTransportError 网络层错误，超时单独归类
+func wrapTransportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &Error{Code: defs.ErrCodeLLMUpstreamTimeout, Message: err.Error()}
+	}
+	if errors.Is(err, context.Canceled) { // 客户端主动断开，不属于上游错误
+		return err
+	}
+	return &Error{Code: defs.ErrCodeLLMUpstreamError, Message: err.Error()}
+}
+
+// checkAnswer 检查补全结果，内容过滤和空结果转换为对应的错误
+func checkAnswer(resp *ChatResponse) error {
+	if resp.FinishReason == finishReasonContentFilter {
+		return newError(defs.ErrCodeLLMContentFiltered, "answer filtered by upstream content policy")
+	}
+	if strings.TrimSpace(resp.Content) == "" {
+		return newError(defs.ErrCodeLLMEmptyAnswer, "upstream returned empty answer")
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	jsoniter "github.com/json-iterator/go"
+	"simpletools/internal/configs"
+	"simpletools/internal/defs"
+	"strings"
+)
+
+const (
+	sseDataPrefix = "data:"
+	sseDone       = "[DONE]"
+)
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIReq struct {
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	Stream        bool                 `json:"stream"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"` // 流式时要求最后一个分片携带用量
+}
+
+type openAIPromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+type openAIUsage struct {
+	PromptTokens         int                       `json:"prompt_tokens"`
+	CompletionTokens     int                       `json:"completion_tokens"`
+	TotalTokens          int                       `json:"total_tokens"`
+	PromptTokensDetails  openAIPromptTokensDetails `json:"prompt_tokens_details"`
+	PromptCacheHitTokens int                       `json:"prompt_cache_hit_tokens"` // DeepSeek特有字段
+}
+
+type openAILogprobs struct {
+	TokenLogprobs      []float64 `json:"token_logprobs"`
+	TokenTextOffset    []int     `json:"token_text_offset"`
+	SequenceLogprobs   []float64 `json:"sequence_logprobs"`
+	CompletionLogprobs []float64 `json:"completion_logprobs"`
+}
+
+type openAIChoice struct {
+	Index        int            `json:"index"`
+	Message      Message        `json:"message"`
+	FinishReason string         `json:"finish_reason"`
+	Logprobs     openAILogprobs `json:"logprobs"`
+}
+
+type openAIStreamChoice struct {
+	Index        int     `json:"index"`
+	Delta        Message `json:"delta"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+type openAIStreamChunk struct {
+	ID      string               `json:"id"`
+	Model   string               `json:"model"`
+	Usage   *openAIUsage         `json:"usage"` // 仅最后一个分片携带
+	Choices []openAIStreamChoice `json:"choices"`
+	Error   interface{}          `json:"error"`
+}
+
+type openAIResp struct {
+	ID                string         `json:"id"`
+	Object            string         `json:"object"`
+	Created           int64          `json:"created"`
+	Model             string         `json:"model"`
+	Usage             openAIUsage    `json:"usage"`
+	SystemFingerprint string         `json:"system_fingerprint"`
+	Choices           []openAIChoice `json:"choices"`
+}
+
+func (u openAIUsage) toUsage() Usage {
+	cacheHit := u.PromptTokensDetails.CachedTokens
+	if u.PromptCacheHitTokens > cacheHit {
+		cacheHit = u.PromptCacheHitTokens
+	}
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CacheHitTokens:   cacheHit,
+	}
+}
+
+// OpenAICompatible 兼容OpenAI /chat/completions 协议的通用提供方，自建模型网关使用
+type OpenAICompatible struct {
+	cfg    configs.LLMProviderConfig
+	client *Client
+}
+
+func NewOpenAICompatible(cfg configs.LLMProviderConfig) *OpenAICompatible {
+	return &OpenAICompatible{cfg: cfg, client: NewClient(cfg)}
+}
+
+func (p *OpenAICompatible) Name() string {
+	return p.cfg.Name
+}
+
+func (p *OpenAICompatible) Model() string {
+	return p.cfg.Model
+}
+
+func (p *OpenAICompatible) url() string {
+	return strings.TrimRight(p.cfg.BaseURL, "/") + "/chat/completions"
+}
+
+func (p *OpenAICompatible) headers() map[string]string {
+	headers := map[string]string{"Content-Type": "application/json"}
+	if p.cfg.ApiKey != "" {
+		headers["Authorization"] = "Bearer " + p.cfg.ApiKey
+	}
+	for k, v := range p.cfg.Headers {
+		headers[k] = v
+	}
+	return headers
+}
+
+func (p *OpenAICompatible) model(req *ChatRequest) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return p.cfg.Model
+}
+
+func (p *OpenAICompatible) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	bs, err := jsoniter.Marshal(openAIReq{Model: p.model(req), Messages: req.Messages, Stream: false})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Post(ctx, p.url(), bs, p.headers())
+	if err != nil {
+		return nil, err
+	}
+	rlt := &openAIResp{}
+	if err = jsoniter.Unmarshal(resp, rlt); err != nil {
+		return nil, err
+	}
+	if len(rlt.Choices) == 0 {
+		return nil, newError(defs.ErrCodeLLMEmptyAnswer, "upstream response without choices")
+	}
+	result := &ChatResponse{
+		Content:      rlt.Choices[0].Message.Content,
+		FinishReason: rlt.Choices[0].FinishReason,
+		Model:        rlt.Model,
+		Usage:        rlt.Usage.toUsage(),
+	}
+	if err = checkAnswer(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *OpenAICompatible) ChatStream(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error) {
+	bs, err := jsoniter.Marshal(openAIReq{
+		Model:         p.model(req),
+		Messages:      req.Messages,
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.client.PostStream(ctx, p.url(), bs, p.headers())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	result := &ChatResponse{}
+	var content strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, sseDataPrefix) { // 空行、注释行(: keep-alive)等直接忽略
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, sseDataPrefix))
+		if payload == sseDone {
+			break
+		}
+		chunk := &openAIStreamChunk{}
+		if err = jsoniter.UnmarshalFromString(payload, chunk); err != nil {
+			return nil, err
+		}
+		if chunk.Error != nil { // 部分实现在流中途以错误分片结束
+			return nil, decodeStatusError(0, []byte(payload))
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage.toUsage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if err = onDelta(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, wrapTransportError(err)
+	}
+	result.Content = content.String()
+	if err = checkAnswer(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
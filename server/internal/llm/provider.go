@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"simpletools/internal/configs"
+	"sync/atomic"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+
+	ProviderTypeDeepSeek = "deepseek"
+	ProviderTypeOpenAI   = "openai"
+
+	RouteTranslate = "aitranslate"
+	RouteNamed     = "ainamed"
+)
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Model    string    // 为空时使用提供方的默认模型
+	Messages []Message // 对话消息
+	NoCache  bool      // 跳过缓存直接请求提供方
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	CacheHitTokens   int `json:"cache_hit_tokens"` // 提示词命中提供方缓存的token数
+}
+
+type ChatResponse struct {
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason"`
+	Model        string `json:"model"`
+	Usage        Usage  `json:"usage"`
+	Cached       bool   `json:"cached"` // 结果来自缓存，未请求提供方，Usage为0
+}
+
+// DeltaFunc 流式输出时每收到一段增量文本回调一次，返回错误则中断流
+type DeltaFunc func(delta string) error
+
+// Provider 大模型提供方，输入对话补全请求，输出文本和用量
+type Provider interface {
+	Name() string
+	Model() string
+	// Chat 返回的错误为*Error时可通过ErrCode区分额度不足、超时、内容过滤、空结果等情况
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+	// ChatStream 流式对话，增量通过onDelta回调，结束后返回完整文本、用量和结束原因
+	ChatStream(ctx context.Context, req *ChatRequest, onDelta DeltaFunc) (*ChatResponse, error)
+}
+
+// NewChatRequest 构造常用的 系统提示词+用户内容 请求
+func NewChatRequest(systemContent, userContent string, noCache bool) *ChatRequest {
+	return &ChatRequest{
+		Messages: []Message{{Role: RoleSystem, Content: systemContent}, {Role: RoleUser, Content: userContent}},
+		NoCache:  noCache,
+	}
+}
+
+func NewProvider(cfg configs.LLMProviderConfig) (Provider, error) {
+	switch cfg.Type {
+	case ProviderTypeDeepSeek:
+		return NewDeepSeek(cfg), nil
+	case ProviderTypeOpenAI:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("llm provider %s base_url is empty", cfg.Name)
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("llm provider %s model is empty", cfg.Name)
+		}
+		return NewOpenAICompatible(cfg), nil
+	}
+	return nil, fmt.Errorf("llm provider %s unknown type:%s", cfg.Name, cfg.Type)
+}
+
+// routeTable 提供方和路由，创建后只读，热加载时整体替换
+type routeTable struct {
+	providers map[string]Provider
+	routes    map[string]string
+	fallback  string
+}
+
+// Manager 按路由选择提供方，线程安全
+type Manager struct {
+	table       atomic.Pointer[routeTable]
+	cache       *Cache // 未启用时为nil
+	persistPath string
+}
+
+func NewManager(cfg configs.LLMConfig) (*Manager, error) {
+	m := &Manager{persistPath: cfg.Cache.PersistPath}
+	if cfg.Cache.Enable {
+		m.cache = NewCache(cfg.Cache)
+		if m.persistPath != "" {
+			if err := m.cache.Load(m.persistPath); err != nil {
+				return nil, fmt.Errorf("llm cache load failed: %w", err)
+			}
+		}
+	}
+	if err := m.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reload 按新配置重建提供方和路由，失败时保留原有配置；缓存配置只在启动时生效
+func (m *Manager) Reload(cfg configs.LLMConfig) error {
+	t := &routeTable{
+		providers: make(map[string]Provider),
+		routes:    make(map[string]string),
+		fallback:  cfg.Default,
+	}
+	for _, pc := range cfg.Providers {
+		if pc.Name == "" {
+			pc.Name = pc.Type
+		}
+		if _, ok := t.providers[pc.Name]; ok {
+			return fmt.Errorf("llm provider %s duplicated", pc.Name)
+		}
+		p, err := NewProvider(pc)
+		if err != nil {
+			return err
+		}
+		if m.cache != nil {
+			p = &cachedProvider{Provider: p, cache: m.cache}
+		}
+		t.providers[pc.Name] = p
+		if t.fallback == "" {
+			t.fallback = pc.Name
+		}
+	}
+	if _, ok := t.providers[t.fallback]; !ok {
+		return fmt.Errorf("llm default provider %s not found", t.fallback)
+	}
+	for route, name := range cfg.Routes {
+		if _, ok := t.providers[name]; !ok {
+			return fmt.Errorf("llm route %s use unknown provider:%s", route, name)
+		}
+		t.routes[route] = name
+	}
+	m.table.Store(t)
+	return nil
+}
+
+// Get 获取路由对应的提供方，未配置的路由使用默认提供方
+func (m *Manager) Get(route string) Provider {
+	t := m.table.Load()
+	if name, ok := t.routes[route]; ok {
+		return t.providers[name]
+	}
+	return t.providers[t.fallback]
+}
+
+// SaveCache 进程退出前持久化缓存，未启用缓存或未配置路径时什么都不做
+func (m *Manager) SaveCache() error {
+	if m.cache == nil || m.persistPath == "" {
+		return nil
+	}
+	return m.cache.Save(m.persistPath)
+}
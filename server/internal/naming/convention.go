@@ -0,0 +1,226 @@
+package naming
+
+import (
+	"fmt"
+	"simpletools/internal/defs"
+	"simpletools/internal/utils"
+	"strings"
+)
+
+// goInitialisms Go代码中需要保持全大写的缩写，参考golint
+var goInitialisms = utils.NewSet[string]()
+
+func init() {
+	for _, w := range []string{
+		"acl", "api", "ascii", "cpu", "css", "dns", "eof", "guid", "html", "http", "https", "id", "ip", "json",
+		"lhs", "qps", "ram", "rhs", "rpc", "sla", "smtp", "sql", "ssh", "tcp", "tls", "ttl", "udp", "ui", "uid",
+		"uuid", "uri", "url", "utf8", "vm", "xml", "xmpp", "xsrf", "xss",
+	} {
+		goInitialisms.Add(w)
+	}
+}
+
+var styleNames = map[defs.VariableType]string{
+	defs.VariableTypePascalCase: "大驼峰",
+	defs.VariableTypeCamelCase:  "小驼峰",
+	defs.VariableTypeSnakeCase:  "下划线",
+	defs.VariableTypeUpperCase:  "全大写下划线常量",
+	defs.VariableTypeKebabCase:  "中划线连接的全小写(kebab-case)",
+	defs.VariableTypeDotCase:    "点号连接的全小写(dot.case)",
+}
+
+var languageNames = map[defs.CodeLanguage]string{
+	defs.CodeLanguageGo:         "Go",
+	defs.CodeLanguageTypeScript: "TypeScript",
+	defs.CodeLanguagePython:     "Python",
+	defs.CodeLanguageRust:       "Rust",
+	defs.CodeLanguageSQL:        "SQL",
+	defs.CodeLanguageCSS:        "CSS",
+}
+
+var kindNames = map[defs.IdentifierKind]string{
+	defs.IdentifierKindFunction:  "函数",
+	defs.IdentifierKindVariable:  "变量",
+	defs.IdentifierKindConstant:  "常量",
+	defs.IdentifierKindType:      "类型",
+	defs.IdentifierKindFile:      "文件名",
+	defs.IdentifierKindColumn:    "数据库字段",
+	defs.IdentifierKindFlag:      "命令行参数",
+	defs.IdentifierKindConfigKey: "配置项key",
+}
+
+var fileExts = map[defs.CodeLanguage]string{
+	defs.CodeLanguageGo:         ".go",
+	defs.CodeLanguageTypeScript: ".ts",
+	defs.CodeLanguagePython:     ".py",
+	defs.CodeLanguageRust:       ".rs",
+	defs.CodeLanguageSQL:        ".sql",
+	defs.CodeLanguageCSS:        ".css",
+}
+
+// kindStyles 各语言不同命名对象的惯用风格，未列出的种类使用该语言的variable风格
+var kindStyles = map[defs.CodeLanguage]map[defs.IdentifierKind]defs.VariableType{
+	defs.CodeLanguageGo: {
+		defs.IdentifierKindFunction: defs.VariableTypeCamelCase, // 导出时为大驼峰
+		defs.IdentifierKindVariable: defs.VariableTypeCamelCase,
+		defs.IdentifierKindConstant: defs.VariableTypeCamelCase,
+		defs.IdentifierKindType:     defs.VariableTypeCamelCase,
+		defs.IdentifierKindFile:     defs.VariableTypeSnakeCase,
+		defs.IdentifierKindColumn:   defs.VariableTypeSnakeCase,
+	},
+	defs.CodeLanguageTypeScript: {
+		defs.IdentifierKindFunction: defs.VariableTypeCamelCase,
+		defs.IdentifierKindVariable: defs.VariableTypeCamelCase,
+		defs.IdentifierKindConstant: defs.VariableTypeUpperCase,
+		defs.IdentifierKindType:     defs.VariableTypePascalCase,
+		defs.IdentifierKindFile:     defs.VariableTypeKebabCase,
+		defs.IdentifierKindColumn:   defs.VariableTypeCamelCase,
+	},
+	defs.CodeLanguagePython: {
+		defs.IdentifierKindFunction: defs.VariableTypeSnakeCase,
+		defs.IdentifierKindVariable: defs.VariableTypeSnakeCase,
+		defs.IdentifierKindConstant: defs.VariableTypeUpperCase,
+		defs.IdentifierKindType:     defs.VariableTypePascalCase,
+		defs.IdentifierKindFile:     defs.VariableTypeSnakeCase,
+		defs.IdentifierKindColumn:   defs.VariableTypeSnakeCase,
+	},
+	defs.CodeLanguageRust: {
+		defs.IdentifierKindFunction: defs.VariableTypeSnakeCase,
+		defs.IdentifierKindVariable: defs.VariableTypeSnakeCase,
+		defs.IdentifierKindConstant: defs.VariableTypeUpperCase,
+		defs.IdentifierKindType:     defs.VariableTypePascalCase,
+		defs.IdentifierKindFile:     defs.VariableTypeSnakeCase,
+		defs.IdentifierKindColumn:   defs.VariableTypeSnakeCase,
+	},
+	defs.CodeLanguageSQL: {
+		defs.IdentifierKindVariable: defs.VariableTypeSnakeCase,
+		defs.IdentifierKindConstant: defs.VariableTypeUpperCase,
+	},
+	defs.CodeLanguageCSS: {
+		defs.IdentifierKindVariable: defs.VariableTypeKebabCase,
+	},
+}
+
+// kindOnlyStyles 与语言无关的命名对象
+var kindOnlyStyles = map[defs.IdentifierKind]defs.VariableType{
+	defs.IdentifierKindFlag:      defs.VariableTypeKebabCase,
+	defs.IdentifierKindConfigKey: defs.VariableTypeDotCase,
+	defs.IdentifierKindColumn:    defs.VariableTypeSnakeCase,
+}
+
+// Convention 一次命名请求的规则，决定提示词和结果的后处理方式
+type Convention struct {
+	Style       defs.VariableType
+	Language    defs.CodeLanguage   // 为空表示不限定语言
+	Kind        defs.IdentifierKind // 为空表示不限定种类
+	Exported    bool                // Go导出名称
+	Initialisms bool                // 缩写保持全大写，如 userID、HTTPServer
+	Ext         string              // 文件名的扩展名
+}
+
+// NewConvention style大于0时强制使用该风格，否则根据语言和种类推导；language和kind都为空时保持原有的大驼峰默认值
+func NewConvention(style defs.VariableType, language defs.CodeLanguage, kind defs.IdentifierKind, exported bool) (Convention, error) {
+	language = strings.ToLower(language)
+	kind = strings.ToLower(kind)
+	if language != "" && languageNames[language] == "" {
+		return Convention{}, fmt.Errorf("unsupported language:%s", language)
+	}
+	if kind != "" && kindNames[kind] == "" {
+		return Convention{}, fmt.Errorf("unsupported identifier kind:%s", kind)
+	}
+	if style != 0 && styleNames[style] == "" {
+		return Convention{}, fmt.Errorf("unsupported style:%d", style)
+	}
+
+	conv := Convention{Style: style, Language: language, Kind: kind, Exported: exported}
+	if conv.Style == 0 {
+		conv.Style = conventionStyle(language, kind)
+	}
+	if language == defs.CodeLanguageGo && kind != defs.IdentifierKindFile && kind != defs.IdentifierKindColumn {
+		conv.Initialisms = conv.Style == defs.VariableTypeCamelCase || conv.Style == defs.VariableTypePascalCase
+		if exported && conv.Style == defs.VariableTypeCamelCase {
+			conv.Style = defs.VariableTypePascalCase
+		}
+	}
+	if kind == defs.IdentifierKindFile {
+		conv.Ext = fileExts[language]
+	}
+	return conv, nil
+}
+
+func conventionStyle(language defs.CodeLanguage, kind defs.IdentifierKind) defs.VariableType {
+	if styles, ok := kindStyles[language]; ok {
+		if style, ok := styles[kind]; ok {
+			return style
+		}
+	}
+	if style, ok := kindOnlyStyles[kind]; ok {
+		return style
+	}
+	if styles, ok := kindStyles[language]; ok {
+		if style, ok := styles[defs.IdentifierKindVariable]; ok {
+			return style
+		}
+	}
+	return defs.VariableTypePascalCase
+}
+
+// StyleName 风格的中文描述，用于提示词
+func StyleName(style defs.VariableType) string {
+	if name, ok := styleNames[style]; ok {
+		return name
+	}
+	return styleNames[defs.VariableTypePascalCase]
+}
+
+// Describe 提示词中对本次命名规则的描述
+func (c Convention) Describe() string {
+	desc := StyleName(c.Style)
+	if c.Language != "" {
+		desc += fmt.Sprintf("，目标语言为%s，遵循该语言社区的命名惯例", languageNames[c.Language])
+	}
+	if c.Kind != "" {
+		desc += fmt.Sprintf("，命名对象为%s", kindNames[c.Kind])
+	}
+	if c.Language == defs.CodeLanguageGo && c.Kind != defs.IdentifierKindFile && c.Kind != defs.IdentifierKindColumn {
+		if c.Exported {
+			desc += "，需要导出"
+		} else {
+			desc += "，不导出"
+		}
+	}
+	if c.Initialisms {
+		desc += "，ID、URL、HTTP等缩写保持全大写"
+	}
+	if c.Ext != "" {
+		desc += fmt.Sprintf("，包含扩展名%s", c.Ext)
+	}
+	return desc
+}
+
+// Apply 按规则把单词拼接成最终名称
+func (c Convention) Apply(words []string) string {
+	if !c.Initialisms {
+		return Format(words, c.Style) + c.Ext
+	}
+	var sb strings.Builder
+	for i, w := range words {
+		switch {
+		case i == 0 && c.Style == defs.VariableTypeCamelCase: // 不导出的名称首个单词全小写，如 urlParser
+			sb.WriteString(w)
+		case goInitialisms.Contain(w):
+			sb.WriteString(strings.ToUpper(w))
+		default:
+			sb.WriteString(title(w))
+		}
+	}
+	return sb.String() + c.Ext
+}
+
+// trimExt 去掉模型给出的扩展名，避免被拆成单词
+func (c Convention) trimExt(name string) string {
+	if c.Ext != "" && strings.HasSuffix(strings.ToLower(name), c.Ext) {
+		return name[:len(name)-len(c.Ext)]
+	}
+	return name
+}
@@ -0,0 +1,151 @@
+package naming
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"regexp"
+	"simpletools/internal/defs"
+	"simpletools/internal/utils"
+	"strings"
+	"unicode"
+)
+
+var (
+	numberedRe = regexp.MustCompile(`(?:^|\s)\d+\s*[.、)）]\s*`)                    // 1.xxx 2、xxx 3) xxx
+	tokenTrim  = "`'\"“”‘’*[]()（）<>《》:：,，;；"                                      // 候选名称两侧需要去掉的符号
+	fenceRe    = regexp.MustCompile("(?s)^\\s*```[a-zA-Z]*\\s*(.*?)\\s*```\\s*$") // markdown代码块
+)
+
+// ParseCandidates 从模型输出中提取候选名称，兼容 JSON数组、1.xxx 2.xxx、每行一个 等格式，只做提取不做校验
+func ParseCandidates(text string) []string {
+	text = strings.TrimSpace(text)
+	if m := fenceRe.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+	var list []string
+	if strings.HasPrefix(text, "[") && jsoniter.UnmarshalFromString(text, &list) == nil {
+		return list
+	}
+
+	var parts []string
+	if locs := numberedRe.FindAllStringIndex(text, -1); len(locs) > 0 {
+		for i, loc := range locs {
+			end := len(text)
+			if i+1 < len(locs) {
+				end = locs[i+1][0]
+			}
+			parts = append(parts, text[loc[1]:end])
+		}
+	} else {
+		parts = strings.FieldsFunc(text, func(r rune) bool {
+			return r == '\n' || r == ',' || r == '，' || r == ';' || r == '；'
+		})
+	}
+
+	list = make([]string, 0, len(parts))
+	for _, part := range parts {
+		fields := strings.Fields(strings.Trim(strings.TrimSpace(part), tokenTrim))
+		if len(fields) == 0 {
+			continue
+		}
+		list = append(list, strings.Trim(fields[0], tokenTrim)) // 只取名称，丢弃后面的解释
+	}
+	return list
+}
+
+// SplitWords 把任意风格的名称拆成小写单词：getHTTPServer2 -> [get http server2]，user_id -> [user id]
+func SplitWords(name string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.ToLower(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)):
+			flush() // 分隔符：_ - . 空格 以及非ASCII字符
+		case unicode.IsUpper(r):
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			prevUpper := i > 0 && unicode.IsUpper(runes[i-1])
+			if prevLower || (prevUpper && nextLower) { // fooBar | HTTPServer 的 S
+				flush()
+			}
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return words
+}
+
+func title(word string) string {
+	if word == "" {
+		return word
+	}
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+// Format 按命名风格重新拼接单词，未知风格按大驼峰处理
+func Format(words []string, style defs.VariableType) string {
+	switch style {
+	case defs.VariableTypeCamelCase:
+		var sb strings.Builder
+		for i, w := range words {
+			if i == 0 {
+				sb.WriteString(w)
+			} else {
+				sb.WriteString(title(w))
+			}
+		}
+		return sb.String()
+	case defs.VariableTypeSnakeCase:
+		return strings.Join(words, "_")
+	case defs.VariableTypeUpperCase:
+		return strings.ToUpper(strings.Join(words, "_"))
+	case defs.VariableTypeKebabCase:
+		return strings.Join(words, "-")
+	case defs.VariableTypeDotCase:
+		return strings.Join(words, ".")
+	}
+	var sb strings.Builder
+	for _, w := range words {
+		sb.WriteString(title(w))
+	}
+	return sb.String()
+}
+
+// valid 单词只包含ASCII字母数字，且名称以字母开头
+func valid(words []string) bool {
+	if len(words) == 0 || len(words[0]) == 0 {
+		return false
+	}
+	first := rune(words[0][0])
+	return first <= unicode.MaxASCII && unicode.IsLetter(first)
+}
+
+// Normalize 校验候选名称并按规则重新转换大小写，去重后保持原有顺序，最多返回limit个
+func Normalize(candidates []string, conv Convention, limit int) []string {
+	seen := utils.NewSet[string]()
+	names := make([]string, 0, limit)
+	for _, candidate := range candidates {
+		words := SplitWords(conv.trimExt(candidate))
+		if !valid(words) {
+			continue
+		}
+		name := conv.Apply(words)
+		if seen.Contain(name) {
+			continue
+		}
+		seen.Add(name)
+		names = append(names, name)
+		if len(names) >= limit {
+			break
+		}
+	}
+	return names
+}
@@ -0,0 +1,217 @@
+package naming
+
+import (
+	"reflect"
+	"simpletools/internal/defs"
+	"testing"
+)
+
+func TestParseCandidates(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"json array", `["userName", "user_name"]`, []string{"userName", "user_name"}},
+		{"json in fence", "```json\n[\"userName\",\"loginUser\"]\n```", []string{"userName", "loginUser"}},
+		{"numbered", "1. userName 用户名\n2、loginUser\n3) `currentUser` ：当前用户", []string{"userName", "loginUser", "currentUser"}},
+		{"numbered single line", "1.userName 2.loginUser 3.currentUser", []string{"userName", "loginUser", "currentUser"}},
+		{"per line", "userName\n\n  loginUser - 登录用户\n**currentUser**", []string{"userName", "loginUser", "currentUser"}},
+		{"comma separated", "userName, loginUser，currentUser；owner", []string{"userName", "loginUser", "currentUser", "owner"}},
+		{"quoted", `"userName", 'loginUser', “currentUser”`, []string{"userName", "loginUser", "currentUser"}},
+		{"invalid json falls back to lines", "[userName\nloginUser", []string{"userName", "loginUser"}},
+		{"empty", "  \n ", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ParseCandidates(tc.text); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	cases := map[string][]string{
+		"getHTTPServer2":   {"get", "http", "server2"},
+		"user_id":          {"user", "id"},
+		"UserID":           {"user", "id"},
+		"parseURLString":   {"parse", "url", "string"},
+		"MAX_RETRY_COUNT":  {"max", "retry", "count"},
+		"btn-primary":      {"btn", "primary"},
+		"server.http.port": {"server", "http", "port"},
+		"user name":        {"user", "name"},
+		"用户name":           {"name"},
+		"":                 nil,
+	}
+	for name, want := range cases {
+		if got := SplitWords(name); !reflect.DeepEqual(got, want) {
+			t.Fatalf("SplitWords(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	words := []string{"user", "id", "list"}
+	cases := map[defs.VariableType]string{
+		defs.VariableTypePascalCase: "UserIdList",
+		defs.VariableTypeCamelCase:  "userIdList",
+		defs.VariableTypeSnakeCase:  "user_id_list",
+		defs.VariableTypeUpperCase:  "USER_ID_LIST",
+		defs.VariableTypeKebabCase:  "user-id-list",
+		defs.VariableTypeDotCase:    "user.id.list",
+		0:                           "UserIdList", // 未知风格按大驼峰处理
+	}
+	for style, want := range cases {
+		if got := Format(words, style); got != want {
+			t.Fatalf("Format style %d = %q, want %q", style, got, want)
+		}
+	}
+}
+
+func TestNewConvention(t *testing.T) {
+	cases := []struct {
+		style       defs.VariableType
+		language    string
+		kind        string
+		exported    bool
+		want        defs.VariableType
+		initialisms bool
+		ext         string
+	}{
+		{0, "", "", false, defs.VariableTypePascalCase, false, ""},
+		{defs.VariableTypeSnakeCase, "", "", false, defs.VariableTypeSnakeCase, false, ""},
+		{0, "go", "function", false, defs.VariableTypeCamelCase, true, ""},
+		{0, "Go", "function", true, defs.VariableTypePascalCase, true, ""},
+		{0, "go", "type", true, defs.VariableTypePascalCase, true, ""},
+		{0, "go", "", false, defs.VariableTypeCamelCase, true, ""},
+		{0, "go", "file", true, defs.VariableTypeSnakeCase, false, ".go"},
+		{0, "go", "column", false, defs.VariableTypeSnakeCase, false, ""},
+		{0, "go", "flag", false, defs.VariableTypeKebabCase, false, ""},
+		{defs.VariableTypeSnakeCase, "go", "variable", true, defs.VariableTypeSnakeCase, false, ""},
+		{0, "typescript", "constant", false, defs.VariableTypeUpperCase, false, ""},
+		{0, "typescript", "type", false, defs.VariableTypePascalCase, false, ""},
+		{0, "typescript", "file", false, defs.VariableTypeKebabCase, false, ".ts"},
+		{0, "python", "function", false, defs.VariableTypeSnakeCase, false, ""},
+		{0, "python", "type", false, defs.VariableTypePascalCase, false, ""},
+		{0, "rust", "constant", false, defs.VariableTypeUpperCase, false, ""},
+		{0, "rust", "file", false, defs.VariableTypeSnakeCase, false, ".rs"},
+		{0, "sql", "function", false, defs.VariableTypeSnakeCase, false, ""},
+		{0, "sql", "column", false, defs.VariableTypeSnakeCase, false, ""},
+		{0, "sql", "file", false, defs.VariableTypeSnakeCase, false, ".sql"},
+		{0, "css", "type", false, defs.VariableTypeKebabCase, false, ""},
+		{0, "", "config_key", false, defs.VariableTypeDotCase, false, ""},
+		{0, "", "flag", false, defs.VariableTypeKebabCase, false, ""},
+		{0, "", "column", false, defs.VariableTypeSnakeCase, false, ""},
+		{0, "", "function", false, defs.VariableTypePascalCase, false, ""},
+		{0, "", "file", false, defs.VariableTypePascalCase, false, ""},
+	}
+	for _, tc := range cases {
+		conv, err := NewConvention(tc.style, tc.language, tc.kind, tc.exported)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc, err)
+		}
+		if conv.Style != tc.want || conv.Initialisms != tc.initialisms || conv.Ext != tc.ext {
+			t.Fatalf("%s/%s exported:%v style:%d got %+v", tc.language, tc.kind, tc.exported, tc.style, conv)
+		}
+		if conv.Describe() == "" {
+			t.Fatalf("%+v has no description", conv)
+		}
+	}
+
+	for _, tc := range []struct {
+		style    defs.VariableType
+		language string
+		kind     string
+	}{
+		{0, "java", ""},
+		{0, "", "class"},
+		{99, "", ""},
+	} {
+		if _, err := NewConvention(tc.style, tc.language, tc.kind, false); err == nil {
+			t.Fatalf("%+v accepted", tc)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		language string
+		kind     string
+		exported bool
+		words    []string
+		want     string
+	}{
+		{"go", "variable", false, []string{"user", "id"}, "userID"},
+		{"go", "variable", false, []string{"url", "parser"}, "urlParser"},
+		{"go", "function", true, []string{"get", "http", "server"}, "GetHTTPServer"},
+		{"go", "type", true, []string{"url", "id", "map"}, "URLIDMap"},
+		{"go", "constant", false, []string{"max", "ttl"}, "maxTTL"},
+		{"go", "file", false, []string{"user", "id"}, "user_id.go"},
+		{"go", "column", false, []string{"user", "id"}, "user_id"},
+		{"typescript", "variable", false, []string{"user", "id"}, "userId"},
+		{"typescript", "constant", false, []string{"api", "url"}, "API_URL"},
+		{"typescript", "file", false, []string{"user", "profile"}, "user-profile.ts"},
+		{"python", "type", false, []string{"http", "client"}, "HttpClient"},
+		{"css", "variable", false, []string{"btn", "primary"}, "btn-primary"},
+		{"", "flag", false, []string{"dry", "run"}, "dry-run"},
+		{"", "config_key", false, []string{"server", "http", "port"}, "server.http.port"},
+	}
+	for _, tc := range cases {
+		conv, err := NewConvention(0, tc.language, tc.kind, tc.exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := conv.Apply(tc.words); got != tc.want {
+			t.Fatalf("%s/%s exported:%v %q = %q, want %q", tc.language, tc.kind, tc.exported, tc.words, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name       string
+		style      defs.VariableType
+		language   string
+		kind       string
+		candidates []string
+		limit      int
+		want       []string
+	}{
+		{"recase and dedup", defs.VariableTypeCamelCase, "", "",
+			[]string{"user_name", "UserName", "userName", "user-name", "login_user"}, 10,
+			[]string{"userName", "loginUser"}},
+		{"drop invalid", defs.VariableTypeSnakeCase, "", "",
+			[]string{"2fa_code", "用户名", "_", "user名", "userID"}, 10,
+			[]string{"user", "user_id"}},
+		{"limit keeps order", defs.VariableTypeKebabCase, "", "",
+			[]string{"c", "b", "a", "b", "d"}, 3,
+			[]string{"c", "b", "a"}},
+		{"initialisms dedup", 0, "go", "variable",
+			[]string{"userId", "user_id", "UserID", "httpURL"}, 10,
+			[]string{"userID", "httpURL"}},
+		{"file ext trimmed", 0, "go", "file",
+			[]string{"user_store.go", "UserStore.GO", "user_store", "userHandler"}, 10,
+			[]string{"user_store.go", "user_handler.go"}},
+		{"dot case", 0, "", "config_key",
+			[]string{"server.httpPort", "SERVER_HTTP_PORT", "log-level"}, 10,
+			[]string{"server.http.port", "log.level"}},
+		{"explanation attached to name", defs.VariableTypeCamelCase, "", "",
+			ParseCandidates("1. `currentUser`：当前用户\n2. login_user（登录用户）"), 10,
+			[]string{"currentUser", "loginUser"}},
+		{"upper case", defs.VariableTypeUpperCase, "", "",
+			[]string{"maxRetryCount", "max.retry.count"}, 10,
+			[]string{"MAX_RETRY_COUNT"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conv, err := NewConvention(tc.style, tc.language, tc.kind, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Normalize(tc.candidates, conv, tc.limit); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
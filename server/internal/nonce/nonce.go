@@ -0,0 +1,194 @@
+package nonce
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const bucketSpan = 5 // 过期时间按5秒分桶，整桶删除
+
+var (
+	ErrReplay = errors.New("nonce already used")
+	ErrFull   = errors.New("nonce store is full")
+)
+
+// Store 记录时间窗口内使用过的nonce防止重放，可以替换为redis等多实例共享的实现
+type Store interface {
+	// Use 记录client的nonce直到expiresAt，窗口内重复使用返回ErrReplay，容量已满返回ErrFull
+	Use(client, nonce string, expiresAt int64) error
+	// Clean 删除已过期的nonce，由主循环定期调用
+	Clean(now int64)
+	Stats() Stats
+	// Close 进程退出时调用一次，之后Use都返回ErrFull
+	Close() error
+}
+
+// Stats 当前容量和各客户端的计数，计数从启动开始累计，Clients只包含仍有nonce的客户端
+type Stats struct {
+	Size     int64         `json:"size"`
+	MaxSize  int           `json:"max_size"`
+	Accepted int64         `json:"accepted"`
+	Replayed int64         `json:"replayed"` // 拒绝的重放请求
+	Full     int64         `json:"full"`     // 因容量已满拒绝的请求
+	Clients  []ClientStats `json:"clients"`
+}
+
+type ClientStats struct {
+	Client   string `json:"client"` // 未签名的请求为 ip:来源地址
+	Size     int    `json:"size"`
+	Accepted int64  `json:"accepted"`
+	Replayed int64  `json:"replayed"`
+	Full     int64  `json:"full"`
+}
+
+// MemoryStore 按客户端分区，单个客户端不能占满其他客户端的容量；
+// 每个分区按过期时间分桶，过期时整桶删除，每个nonce只在写入和删除时各处理一次
+type MemoryStore struct {
+	maxSize   int
+	clientMax int
+	size      atomic.Int64
+	closed    atomic.Bool
+	removed   ClientStats // 已删除的空分区的累计计数，持有mu时修改
+
+	mu         sync.RWMutex
+	partitions map[string]*partition
+}
+
+type partition struct {
+	mu       sync.Mutex
+	index    map[string]struct{}
+	buckets  map[int64][]string // 过期时间/bucketSpan -> nonce
+	oldest   int64              // 可能还有数据的最早的桶
+	removed  bool               // 已从partitions中删除，持有该分区锁的Use需要重新获取分区
+	accepted atomic.Int64
+	replayed atomic.Int64
+	full     atomic.Int64
+}
+
+// NewMemoryStore maxSize为所有客户端合计的上限，clientMax为单个客户端的上限
+func NewMemoryStore(maxSize, clientMax int) *MemoryStore {
+	return &MemoryStore{maxSize: maxSize, clientMax: clientMax, partitions: make(map[string]*partition)}
+}
+
+func (s *MemoryStore) partition(client string) *partition {
+	s.mu.RLock()
+	p, ok := s.partitions[client]
+	s.mu.RUnlock()
+	if ok {
+		return p
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok = s.partitions[client]; !ok {
+		p = &partition{index: make(map[string]struct{}), buckets: make(map[int64][]string)}
+		s.partitions[client] = p
+	}
+	return p
+}
+
+func (s *MemoryStore) Use(client, nonce string, expiresAt int64) error {
+	if s.closed.Load() {
+		return ErrFull
+	}
+	p := s.partition(client)
+	p.mu.Lock()
+	for p.removed {
+		p.mu.Unlock()
+		p = s.partition(client)
+		p.mu.Lock()
+	}
+	defer p.mu.Unlock()
+	s.expire(p, time.Now().Unix())
+	if _, ok := p.index[nonce]; ok {
+		p.replayed.Add(1)
+		return ErrReplay
+	}
+	// 容量满时拒绝新请求而不是淘汰旧nonce，淘汰会让窗口内的请求可以重放
+	if len(p.index) >= s.clientMax || s.size.Load() >= int64(s.maxSize) {
+		p.full.Add(1)
+		return ErrFull
+	}
+	b := expiresAt / bucketSpan
+	if len(p.index) == 0 || b < p.oldest {
+		p.oldest = b
+	}
+	p.index[nonce] = struct{}{}
+	p.buckets[b] = append(p.buckets[b], nonce)
+	s.size.Add(1)
+	p.accepted.Add(1)
+	return nil
+}
+
+// expire 删除桶内全部过期(桶的结束时间不晚于now)的桶，调用方持有分区锁
+func (s *MemoryStore) expire(p *partition, now int64) {
+	end := now / bucketSpan // 编号小于end的桶已全部过期
+	if len(p.index) == 0 {
+		p.oldest = end
+		return
+	}
+	for ; p.oldest < end; p.oldest++ {
+		nonces, ok := p.buckets[p.oldest]
+		if !ok {
+			continue
+		}
+		for _, n := range nonces {
+			delete(p.index, n)
+		}
+		delete(p.buckets, p.oldest)
+		s.size.Add(-int64(len(nonces)))
+	}
+}
+
+// Clean 同时删除已经没有nonce的分区，未签名的请求按来源地址分区，分区数量随来源增长
+func (s *MemoryStore) Clean(now int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client, p := range s.partitions {
+		p.mu.Lock()
+		s.expire(p, now)
+		if len(p.index) == 0 {
+			p.removed = true
+			delete(s.partitions, client)
+			s.removed.Accepted += p.accepted.Load()
+			s.removed.Replayed += p.replayed.Load()
+			s.removed.Full += p.full.Load()
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (s *MemoryStore) Stats() Stats {
+	stats := Stats{Size: s.size.Load(), MaxSize: s.maxSize, Clients: make([]ClientStats, 0)}
+	s.mu.RLock()
+	stats.Accepted, stats.Replayed, stats.Full = s.removed.Accepted, s.removed.Replayed, s.removed.Full
+	for client, p := range s.partitions {
+		p.mu.Lock()
+		size := len(p.index)
+		p.mu.Unlock()
+		cs := ClientStats{Client: client, Size: size, Accepted: p.accepted.Load(), Replayed: p.replayed.Load(), Full: p.full.Load()}
+		stats.Accepted += cs.Accepted
+		stats.Replayed += cs.Replayed
+		stats.Full += cs.Full
+		stats.Clients = append(stats.Clients, cs)
+	}
+	s.mu.RUnlock()
+	sort.Slice(stats.Clients, func(i, j int) bool {
+		return stats.Clients[i].Client < stats.Clients[j].Client
+	})
+	return stats
+}
+
+// Close 只保存在内存中，关闭后释放所有nonce
+func (s *MemoryStore) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partitions = make(map[string]*partition)
+	s.size.Store(0)
+	return nil
+}
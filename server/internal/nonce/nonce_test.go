@@ -0,0 +1,218 @@
+package nonce
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// future 与桶边界对齐的未来时间，Use内部按当前时间清理不会影响这些nonce
+func future() int64 {
+	return (time.Now().Unix()/bucketSpan + 100) * bucketSpan
+}
+
+func TestUse(t *testing.T) {
+	exp := future()
+	past := time.Now().Unix() - 2*bucketSpan
+	type use struct {
+		client, nonce string
+		expiresAt     int64
+		err           error
+	}
+	cases := []struct {
+		name string
+		uses []use
+	}{
+		{"replay", []use{{"a", "n1", exp, nil}, {"a", "n1", exp, ErrReplay}, {"a", "n1", exp + 60, ErrReplay}}},
+		{"same nonce other client", []use{{"a", "n1", exp, nil}, {"b", "n1", exp, nil}}},
+		{"expired nonce can be reused", []use{{"a", "n1", past, nil}, {"a", "n1", exp, nil}, {"a", "n1", exp, ErrReplay}}},
+		{"client full", []use{{"a", "n1", exp, nil}, {"a", "n2", exp, nil}, {"a", "n3", exp, ErrFull}, {"b", "n3", exp, nil}}},
+		{"global full", []use{{"a", "n1", exp, nil}, {"b", "n1", exp, nil}, {"c", "n1", exp, nil}, {"d", "n1", exp, ErrFull}, {"a", "n2", exp, ErrFull}}},
+		{"replay checked before full", []use{{"a", "n1", exp, nil}, {"a", "n2", exp, nil}, {"a", "n1", exp, ErrReplay}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewMemoryStore(3, 2)
+			for i, u := range tc.uses {
+				if err := s.Use(u.client, u.nonce, u.expiresAt); !errors.Is(err, u.err) {
+					t.Fatalf("use %d %+v: err %v, want %v", i, u, err, u.err)
+				}
+			}
+		})
+	}
+}
+
+func TestExpireBucketEdge(t *testing.T) {
+	base := future()
+	cases := []struct {
+		name      string
+		expiresAt int64
+		cleanAt   int64
+		kept      bool
+	}{
+		{"bucket start, clean at expiry", base, base, true},
+		{"bucket end, clean at expiry", base + bucketSpan - 1, base + bucketSpan - 1, true},
+		{"bucket start, clean at next bucket", base, base + bucketSpan, false},
+		{"bucket end, clean at next bucket", base + bucketSpan - 1, base + bucketSpan, false},
+		{"next bucket", base + bucketSpan, base + bucketSpan, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewMemoryStore(10, 10)
+			if err := s.Use("a", "n", tc.expiresAt); err != nil {
+				t.Fatal(err)
+			}
+			s.Clean(tc.cleanAt)
+			if kept := s.Stats().Size == 1; kept != tc.kept {
+				t.Fatalf("kept %v, want %v", kept, tc.kept)
+			}
+			if err := s.Use("a", "n", tc.expiresAt); tc.kept != errors.Is(err, ErrReplay) {
+				t.Fatalf("reuse err %v, kept %v", err, tc.kept)
+			}
+		})
+	}
+}
+
+func TestCleanStats(t *testing.T) {
+	base := future()
+	s := NewMemoryStore(5, 2)
+	uses := []struct {
+		client    string
+		nonce     string
+		expiresAt int64
+	}{
+		{"a", "1", base}, {"a", "1", base}, {"a", "2", base}, {"a", "3", base},
+		{"b", "1", base + 60}, {"b", "1", base + 60},
+		{"c", "1", base}, {"c", "2", base + 60},
+	}
+	for _, u := range uses {
+		_ = s.Use(u.client, u.nonce, u.expiresAt)
+	}
+	want := Stats{Size: 5, MaxSize: 5, Accepted: 5, Replayed: 2, Full: 1, Clients: []ClientStats{
+		{Client: "a", Size: 2, Accepted: 2, Replayed: 1, Full: 1},
+		{Client: "b", Size: 1, Accepted: 1, Replayed: 1},
+		{Client: "c", Size: 2, Accepted: 2},
+	}}
+	assertStats(t, s.Stats(), want)
+
+	// a的nonce全部过期，分区被删除，计数仍计入总数
+	s.Clean(base + bucketSpan)
+	want = Stats{Size: 2, MaxSize: 5, Accepted: 5, Replayed: 2, Full: 1, Clients: []ClientStats{
+		{Client: "b", Size: 1, Accepted: 1, Replayed: 1},
+		{Client: "c", Size: 1, Accepted: 2},
+	}}
+	assertStats(t, s.Stats(), want)
+	if len(s.partitions) != 2 {
+		t.Fatalf("%d partitions after clean, want 2", len(s.partitions))
+	}
+
+	// 删除后重新使用的客户端从0开始计数
+	if err := s.Use("a", "1", base+60); err != nil {
+		t.Fatal(err)
+	}
+	s.Clean(base + 60 + bucketSpan)
+	want = Stats{Size: 0, MaxSize: 5, Accepted: 6, Replayed: 2, Full: 1, Clients: []ClientStats{}}
+	assertStats(t, s.Stats(), want)
+	if len(s.partitions) != 0 {
+		t.Fatalf("%d partitions left", len(s.partitions))
+	}
+}
+
+func assertStats(t *testing.T, got, want Stats) {
+	t.Helper()
+	if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+		t.Fatalf("stats\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// TestUseRemovedPartition Use取到分区后、加锁前分区被Clean删除，需要换到新的分区，否则nonce写入已删除的分区后无法防重放
+func TestUseRemovedPartition(t *testing.T) {
+	exp := future()
+	s := NewMemoryStore(10, 10)
+	p := s.partition("a")
+	p.mu.Lock()
+	done := make(chan error)
+	go func() { done <- s.Use("a", "n", exp) }()
+	time.Sleep(10 * time.Millisecond) // 让Use阻塞在分区锁上
+
+	s.mu.Lock()
+	p.removed = true
+	delete(s.partitions, "a")
+	s.mu.Unlock()
+	p.mu.Unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Use("a", "n", exp); !errors.Is(err, ErrReplay) {
+		t.Fatalf("nonce written to removed partition, reuse err %v", err)
+	}
+	if len(p.index) != 0 {
+		t.Fatal("removed partition modified")
+	}
+}
+
+func TestConcurrentUseClean(t *testing.T) {
+	exp := time.Now().Unix() + 60
+	s := NewMemoryStore(1000000, 1000000)
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				s.Clean(time.Now().Unix())
+			}
+		}
+	}()
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				client := fmt.Sprintf("c%d", i%5)
+				if s.Use(client, fmt.Sprintf("%d-%d", w, i), exp) == nil {
+					accepted.Add(1)
+				}
+				if i%50 == 0 { // 空分区随时可能被删除
+					s.Clean(exp + bucketSpan)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stop)
+
+	// 所有nonce都在仍然存在的分区中，计数没有丢失
+	stats := s.Stats()
+	total := 0
+	for _, cs := range stats.Clients {
+		total += cs.Size
+	}
+	if stats.Accepted != accepted.Load() || int64(total) != stats.Size {
+		t.Fatalf("stats %+v, accepted %d, partition size %d", stats, accepted.Load(), total)
+	}
+	s.Clean(exp + bucketSpan)
+	if stats = s.Stats(); stats.Size != 0 || len(stats.Clients) != 0 {
+		t.Fatalf("stats after final clean %+v", stats)
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := NewMemoryStore(10, 10)
+	_ = s.Use("a", "n", future())
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Use("a", "m", future()); !errors.Is(err, ErrFull) {
+		t.Fatalf("use after close err %v", err)
+	}
+	if stats := s.Stats(); stats.Size != 0 || len(stats.Clients) != 0 {
+		t.Fatalf("stats after close %+v", stats)
+	}
+}
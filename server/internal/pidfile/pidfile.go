@@ -0,0 +1,138 @@
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	lockRetry    = 5
+	lockInterval = 20 * time.Millisecond
+)
+
+// ErrNotRunning pid文件不存在、为空或记录的进程已经退出
+var ErrNotRunning = errors.New("process not running")
+
+// PidFile 进程运行期间持有文件的排他锁，锁随进程退出自动释放，崩溃或kill -9后不影响再次启动；
+// 文件本身不删除，删除后再启动的进程会锁住新的inode，和仍持有旧inode锁的进程同时运行
+type PidFile struct {
+	f *os.File
+}
+
+// Acquire 加锁并写入当前进程pid，其他进程持有锁时返回错误；
+// 能拿到锁说明记录的进程已经退出(pid可能已被其他进程复用)，stale为遗留的pid，0表示没有
+func Acquire(path string) (p *PidFile, stale int, err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, 0, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+		}
+	}()
+	if err = lock(f); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			pid, _ := readPid(f)
+			return nil, 0, fmt.Errorf("process already running in pid:%d", pid)
+		}
+		return nil, 0, err
+	}
+	if pid, _ := readPid(f); pid > 0 && pid != os.Getpid() {
+		stale = pid
+	}
+	if err = f.Truncate(0); err != nil {
+		return nil, 0, err
+	}
+	if _, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		return nil, 0, err
+	}
+	return &PidFile{f: f}, stale, nil
+}
+
+// Release 清空pid文件并释放锁，只能由Acquire成功的进程调用
+func (p *PidFile) Release() error {
+	if p == nil {
+		return nil
+	}
+	err := p.f.Truncate(0)
+	if cErr := p.f.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// Read 读取正在运行的进程pid，只以锁判断，没有进程持有锁时返回ErrNotRunning
+func Read(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, ErrNotRunning
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	pid, err := readPid(f)
+	if err != nil || pid <= 0 {
+		return 0, ErrNotRunning
+	}
+	if syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB) == nil { // 锁已释放，记录的pid可能已被其他进程复用
+		return 0, ErrNotRunning
+	}
+	return pid, nil
+}
+
+// Alive 进程是否存在，没有权限发送信号的进程也视为存在，未被回收的僵尸进程视为已退出
+func Alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	if err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	return !zombie(pid)
+}
+
+// zombie 通过/proc判断进程是否为僵尸状态，没有/proc的系统返回false
+func zombie(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// 格式为 pid (comm) state ...，comm中可能包含括号，从最后一个右括号之后取状态
+	i := strings.LastIndexByte(string(stat), ')')
+	return i >= 0 && i+2 < len(stat) && stat[i+2] == 'Z'
+}
+
+// lock Read会短暂持有共享锁，遇到冲突时重试几次，仍然冲突才认为有其他进程在运行
+func lock(f *os.File) error {
+	var err error
+	for i := 0; i < lockRetry; i++ {
+		if i > 0 {
+			time.Sleep(lockInterval)
+		}
+		if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); !errors.Is(err, syscall.EWOULDBLOCK) {
+			return err
+		}
+	}
+	return err
+}
+
+func readPid(f *os.File) (int, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
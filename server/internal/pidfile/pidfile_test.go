@@ -0,0 +1,83 @@
+package pidfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "test.pid")
+	p, stale, err := Acquire(path)
+	if err != nil || stale != 0 {
+		t.Fatalf("acquire stale:%d err:%v", stale, err)
+	}
+	if _, _, err = Acquire(path); err == nil {
+		t.Fatal("second acquire succeeded while locked")
+	}
+	if pid, err := Read(path); err != nil || pid != os.Getpid() {
+		t.Fatalf("read pid:%d err:%v", pid, err)
+	}
+
+	if err = p.Release(); err != nil {
+		t.Fatal(err)
+	}
+	// 释放后文件保留，内容清空
+	if _, err = os.Stat(path); err != nil {
+		t.Fatalf("pid file removed on release: %v", err)
+	}
+	if _, err = Read(path); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("read after release err:%v, want ErrNotRunning", err)
+	}
+	p, stale, err = Acquire(path)
+	if err != nil || stale != 0 {
+		t.Fatalf("reacquire stale:%d err:%v", stale, err)
+	}
+	_ = p.Release()
+}
+
+func TestStalePid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.pid")
+	if err := os.WriteFile(path, []byte("99999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 没有进程持有锁，记录的pid不可信
+	if _, err := Read(path); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("read unlocked pid file err:%v, want ErrNotRunning", err)
+	}
+	p, stale, err := Acquire(path)
+	if err != nil || stale != 99999999 {
+		t.Fatalf("acquire stale:%d err:%v", stale, err)
+	}
+	_ = p.Release()
+}
+
+func TestAcquireDuringRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.pid")
+	if err := os.WriteFile(path, []byte("99999999"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟Read探测时持有的共享锁，Acquire应等待探测结束而不是判断为已在运行
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan struct{})
+	time.AfterFunc(lockInterval, func() {
+		_ = f.Close()
+		close(released)
+	})
+	defer func() { <-released }()
+
+	p, _, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("acquire during read probe: %v", err)
+	}
+	_ = p.Release()
+}
@@ -0,0 +1,225 @@
+package remote
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"net/http"
+	"os"
+	"simpletools/internal/configs"
+	"simpletools/internal/defs"
+	"simpletools/internal/utils"
+	"simpletools/lib/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	SourceNone   = "none"   // 还没有可用的数据
+	SourceRemote = "remote" // 来自最近一次成功的远端请求
+	SourceLocal  = "local"  // 来自本地副本，远端尚未请求成功
+
+	maxBodySize = 8 << 20
+)
+
+// Status 客户端状态，供管理接口查看
+type Status struct {
+	Addr        string `json:"addr"`
+	Source      string `json:"source"`
+	UpdatedAt   int64  `json:"updated_at"`   // 当前数据的获取时间
+	LastAttempt int64  `json:"last_attempt"` // 最近一次请求远端的时间
+	LastError   string `json:"last_error,omitempty"`
+	Failures    int    `json:"failures"` // 连续失败次数，成功后清零
+}
+
+// Client 定时从remote_addr拉取数据，校验签名并解密，失败时保留上一次可用的数据
+type Client struct {
+	addr     string
+	cfg      configs.RemoteConfig
+	http     *http.Client
+	log      *logger.CustomLogger
+	fetching atomic.Bool // 同一时间只有一个请求
+
+	mu     sync.RWMutex
+	data   *configs.MysqlCommonRespEncrypt
+	status Status
+}
+
+// NewClient 创建时读取本地副本，副本同样需要通过签名校验
+func NewClient(addr string, cfg configs.RemoteConfig, log *logger.CustomLogger) *Client {
+	c := &Client{
+		addr:   addr,
+		cfg:    cfg,
+		http:   &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		log:    log,
+		status: Status{Addr: addr, Source: SourceNone},
+	}
+	if cfg.CachePath == "" {
+		return c
+	}
+	raw, err := os.ReadFile(cfg.CachePath)
+	if os.IsNotExist(err) {
+		return c
+	}
+	if err == nil {
+		var data *configs.MysqlCommonRespEncrypt
+		if data, err = c.Parse(raw); err == nil {
+			info, _ := os.Stat(cfg.CachePath)
+			c.data = data
+			c.status.Source = SourceLocal
+			c.status.UpdatedAt = info.ModTime().Unix()
+			log.Info().Str("path", cfg.CachePath).Msg("remote data load local copy")
+			return c
+		}
+	}
+	log.Warn().Err(err).Str("path", cfg.CachePath).Msg("remote data local copy unusable")
+	return c
+}
+
+// Interval 轮询间隔秒数
+func (c *Client) Interval() int64 {
+	return int64(c.cfg.Interval)
+}
+
+// Data 当前可用的数据，没有数据时返回nil
+func (c *Client) Data() *configs.MysqlCommonRespEncrypt {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.data
+}
+
+func (c *Client) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Refresh 在后台请求一次远端，上一次请求还没结束时直接返回，不阻塞主线程
+func (c *Client) Refresh() {
+	if !c.fetching.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.fetching.Store(false)
+		defer func() {
+			if err := recover(); err != nil {
+				c.log.Error().Msgf("remote refresh panic: %v", err)
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), c.http.Timeout)
+		defer cancel()
+		_ = c.Fetch(ctx)
+	}()
+}
+
+// Fetch 请求远端并替换当前数据，失败时保留原有数据并记录错误
+func (c *Client) Fetch(ctx context.Context) error {
+	now := time.Now().Unix()
+	raw, err := c.get(ctx)
+	var data *configs.MysqlCommonRespEncrypt
+	if err == nil {
+		data, err = c.Parse(raw)
+	}
+
+	c.mu.Lock()
+	c.status.LastAttempt = now
+	if err != nil {
+		c.status.LastError = err.Error()
+		c.status.Failures++
+		failures, source := c.status.Failures, c.status.Source
+		c.mu.Unlock()
+		c.log.Warn().Err(err).Int("failures", failures).Str("source", source).Msg("remote fetch failed, keep last good data")
+		return err
+	}
+	c.data = data
+	c.status.Source = SourceRemote
+	c.status.UpdatedAt = now
+	c.status.LastError = ""
+	c.status.Failures = 0
+	c.mu.Unlock()
+
+	if err = c.save(raw); err != nil {
+		c.log.Warn().Err(err).Str("path", c.cfg.CachePath).Msg("remote save local copy failed")
+	}
+	c.log.Debug().Int64("count", data.Count).Msg("remote fetch success")
+	return nil
+}
+
+func (c *Client) get(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote http status:%d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+}
+
+// Parse 校验CommonResp的签名，解密Data中的Encrypt得到MysqlCommonRespEncrypt
+func (c *Client) Parse(raw []byte) (*configs.MysqlCommonRespEncrypt, error) {
+	var resp configs.CommonResp
+	if err := jsoniter.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("remote response invalid: %w", err)
+	}
+	if !hmac.Equal([]byte(Sign(resp.Code, resp.Data, c.cfg.SignKey)), []byte(resp.Sign)) {
+		return nil, fmt.Errorf("remote response sign mismatch")
+	}
+	if resp.Code != defs.ErrCodeOK {
+		return nil, fmt.Errorf("remote response code:%d", resp.Code)
+	}
+	var enc configs.MysqlCommonResp
+	if err := jsoniter.UnmarshalFromString(resp.Data, &enc); err != nil {
+		return nil, fmt.Errorf("remote response data invalid: %w", err)
+	}
+	plain, err := utils.Decrypt(enc.Encrypt, []byte(c.cfg.EncryptKey))
+	if err != nil {
+		return nil, fmt.Errorf("remote response decrypt failed: %w", err)
+	}
+	var data configs.MysqlCommonRespEncrypt
+	if err = jsoniter.UnmarshalFromString(plain, &data); err != nil {
+		return nil, fmt.Errorf("remote response payload invalid: %w", err)
+	}
+	if !data.OK || data.Err != "" {
+		return nil, fmt.Errorf("remote response not ok: %s", data.Err)
+	}
+	return &data, nil
+}
+
+// save 保存原始响应而不是解密后的内容，本地副本不包含明文，读取时重新校验
+func (c *Client) save(raw []byte) error {
+	if c.cfg.CachePath == "" {
+		return nil
+	}
+	return utils.WriteFileAtomic(c.cfg.CachePath, raw, 0600)
+}
+
+// Sign CommonResp的签名，code和data用换行连接后计算HMAC-SHA256，远端使用相同的算法
+func Sign(code defs.ErrCode, data, key string) string {
+	return utils.HmacSHA256([]byte(fmt.Sprintf("%d\n%s", code, data)), []byte(key))
+}
+
+// Pack 按远端的格式加密并签名，供本地模拟远端和测试使用
+func Pack(data configs.MysqlCommonRespEncrypt, signKey, encryptKey string) ([]byte, error) {
+	plain, err := jsoniter.MarshalToString(data)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.Encrypt(plain, []byte(encryptKey))
+	if err != nil {
+		return nil, err
+	}
+	inner, err := jsoniter.MarshalToString(configs.MysqlCommonResp{Encrypt: encrypted})
+	if err != nil {
+		return nil, err
+	}
+	return jsoniter.Marshal(configs.CommonResp{Code: defs.ErrCodeOK, Data: inner, Sign: Sign(defs.ErrCodeOK, inner, signKey)})
+}
@@ -0,0 +1,118 @@
+package remote
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"simpletools/internal/configs"
+	"simpletools/lib/logger"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	testSignKey    = "remote-sign-key"
+	testEncryptKey = "0123456789abcdef"
+)
+
+// stubServer 返回body中当前保存的响应，测试中途可以替换
+func stubServer(t *testing.T, body *atomic.Value) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := body.Load().([]byte)
+		if raw == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(raw)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func pack(t *testing.T, count int64, signKey, encryptKey string) []byte {
+	t.Helper()
+	raw, err := Pack(configs.MysqlCommonRespEncrypt{Count: count, OK: true}, signKey, encryptKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func newTestClient(addr, cachePath string) *Client {
+	cfg := configs.RemoteConfig{SignKey: testSignKey, EncryptKey: testEncryptKey, Interval: 60, Timeout: 5, CachePath: cachePath}
+	return NewClient(addr, cfg, &logger.CustomLogger{Logger: zerolog.Nop()})
+}
+
+func TestFetch(t *testing.T) {
+	var body atomic.Value
+	srv := stubServer(t, &body)
+	cases := []struct {
+		name string
+		raw  []byte
+		err  string
+	}{
+		{"good payload", pack(t, 3, testSignKey, testEncryptKey), ""},
+		{"bad hmac", pack(t, 3, "other-sign-key", testEncryptKey), "sign mismatch"},
+		{"decrypt failure", pack(t, 3, testSignKey, "fedcba9876543210"), "decrypt failed"},
+		{"http error", nil, "http status:500"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body.Store(tc.raw)
+			c := newTestClient(srv.URL, "")
+			err := c.Fetch(context.Background())
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d := c.Data(); d == nil || d.Count != 3 || c.Status().Source != SourceRemote {
+					t.Fatalf("data %+v status %+v", d, c.Status())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("err %v, want %q", err, tc.err)
+			}
+			if st := c.Status(); c.Data() != nil || st.Source != SourceNone || st.Failures != 1 {
+				t.Fatalf("data %+v status %+v after failure", c.Data(), st)
+			}
+		})
+	}
+}
+
+func TestFallbackToLocalCache(t *testing.T) {
+	var body atomic.Value
+	srv := stubServer(t, &body)
+	cachePath := filepath.Join(t.TempDir(), "remote.json")
+
+	body.Store(pack(t, 7, testSignKey, testEncryptKey))
+	if err := newTestClient(srv.URL, cachePath).Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后远端不可用，使用上次保存的本地副本
+	body.Store(pack(t, 8, "other-sign-key", testEncryptKey))
+	c := newTestClient(srv.URL, cachePath)
+	if d := c.Data(); d == nil || d.Count != 7 || c.Status().Source != SourceLocal {
+		t.Fatalf("data %+v status %+v, want local copy", d, c.Status())
+	}
+	if err := c.Fetch(context.Background()); err == nil {
+		t.Fatal("fetch with bad hmac succeeded")
+	}
+	if d := c.Data(); d == nil || d.Count != 7 || c.Status().Source != SourceLocal {
+		t.Fatalf("data %+v status %+v, want local copy kept", d, c.Status())
+	}
+
+	// 远端恢复后替换本地副本
+	body.Store(pack(t, 9, testSignKey, testEncryptKey))
+	if err := c.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := newTestClient(srv.URL, cachePath).Data(); d == nil || d.Count != 9 {
+		t.Fatalf("local copy %+v, want count 9", d)
+	}
+}
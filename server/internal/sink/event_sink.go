@@ -1,29 +1,173 @@
 package sink
 
-import "simpletools/internal/defs"
+import (
+	"fmt"
+	"runtime/debug"
+	"simpletools/internal/defs"
+	"simpletools/lib/logger"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Event 主循环处理的事件，Source决定交给哪些处理函数
+type Event struct {
+	Source  defs.EEventSinkSource
+	Payload interface{}
+}
+
+// HandlerFunc 在主循环中执行，不能阻塞，耗时操作交给其他协程
+type HandlerFunc func(ev Event)
+
+type counters struct {
+	sent      atomic.Int64
+	dropped   atomic.Int64
+	rejected  atomic.Int64
+	handled   atomic.Int64
+	panics    atomic.Int64
+	unhandled atomic.Int64
+}
+
+// SourceStats 单个来源的事件计数
+type SourceStats struct {
+	Source    defs.EEventSinkSource `json:"source"`
+	Sent      int64                 `json:"sent"`      // 成功进入队列
+	Dropped   int64                 `json:"dropped"`   // 队列满时TrySend丢弃
+	Rejected  int64                 `json:"rejected"`  // 队列关闭后发送
+	Handled   int64                 `json:"handled"`   // 已分发给处理函数
+	Panics    int64                 `json:"panics"`    // 处理函数panic次数
+	Unhandled int64                 `json:"unhandled"` // 没有注册处理函数
+}
 
 type EventSink struct {
-	SinkQ             chan interface{}
+	SinkQ             chan Event
+	log               *logger.CustomLogger
+	closeMu           sync.RWMutex // 只保护closed和关闭队列，主循环不使用，避免Close等待阻塞的发送方时卡住主循环
+	closed            bool
+	mu                sync.RWMutex
+	handlers          map[defs.EEventSinkSource][]HandlerFunc
+	stats             map[defs.EEventSinkSource]*counters
 	exitAfterPakCount int64
 }
 
-func (self *EventSink) SendSinkQ(s defs.EEventSinkSource, in interface{}) {
-	self.SinkQ <- in
+func NewEventSink(size int, log *logger.CustomLogger) *EventSink {
+	return &EventSink{
+		SinkQ:    make(chan Event, size),
+		log:      log,
+		handlers: make(map[defs.EEventSinkSource][]HandlerFunc),
+		stats:    make(map[defs.EEventSinkSource]*counters),
+	}
+}
+
+// On 注册来源对应的处理函数，同一来源按注册顺序依次执行
+func (self *EventSink) On(s defs.EEventSinkSource, handler HandlerFunc) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.handlers[s] = append(self.handlers[s], handler)
 }
 
-func (self *EventSink) CatchException(recoverResult, in interface{}, desc string) {
-	if recoverResult == nil {
+func (self *EventSink) counter(s defs.EEventSinkSource) *counters {
+	self.mu.RLock()
+	c, ok := self.stats[s]
+	self.mu.RUnlock()
+	if ok {
+		return c
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if c, ok = self.stats[s]; !ok {
+		c = &counters{}
+		self.stats[s] = c
+	}
+	return c
+}
+
+// SendSinkQ 队列满时阻塞等待，队列关闭后返回false；只用于启动和信号等非请求路径，请求协程使用TrySend
+func (self *EventSink) SendSinkQ(s defs.EEventSinkSource, in interface{}) bool {
+	c := self.counter(s)
+	self.closeMu.RLock()
+	defer self.closeMu.RUnlock()
+	if self.closed {
+		c.rejected.Add(1)
+		return false
+	}
+	self.SinkQ <- Event{Source: s, Payload: in}
+	c.sent.Add(1)
+	return true
+}
+
+// TrySend 不阻塞发送，队列满或已关闭时丢弃并返回false
+func (self *EventSink) TrySend(s defs.EEventSinkSource, in interface{}) bool {
+	c := self.counter(s)
+	self.closeMu.RLock()
+	defer self.closeMu.RUnlock()
+	if self.closed {
+		c.rejected.Add(1)
+		return false
+	}
+	select {
+	case self.SinkQ <- Event{Source: s, Payload: in}:
+		c.sent.Add(1)
+		return true
+	default:
+		c.dropped.Add(1)
+		return false
+	}
+}
+
+// Close 关闭队列，主循环处理完已在队列中的事件后退出，可以重复调用
+func (self *EventSink) Close() {
+	self.closeMu.Lock()
+	defer self.closeMu.Unlock()
+	if self.closed {
 		return
 	}
+	self.closed = true
+	close(self.SinkQ)
+}
 
+func (self *EventSink) CatchException(recoverResult interface{}, in Event, desc string) {
+	if recoverResult == nil {
+		return
+	}
+	self.counter(in.Source).panics.Add(1)
+	self.log.Error().Int32("source", in.Source).Str("payload", fmt.Sprintf("%T", in.Payload)).
+		Msgf("%s: %v|%s", desc, recoverResult, string(debug.Stack()))
 }
 
-func (self *EventSink) ConsumePak(in interface{}) {
-	//defer func() {
-	//	self.CatchException(recover(), in, "consume pak catch can recover error")
-	//}()
+// ConsumePak 依次执行来源对应的处理函数，单个处理函数panic不影响其他处理函数和主循环
+func (self *EventSink) ConsumePak(in Event) {
+	self.mu.RLock()
+	handlers := self.handlers[in.Source]
+	self.mu.RUnlock()
+	c := self.counter(in.Source)
+	if len(handlers) == 0 {
+		c.unhandled.Add(1)
+		self.log.Warn().Int32("source", in.Source).Str("payload", fmt.Sprintf("%T", in.Payload)).Msg("event sink no handler")
+		return
+	}
+	c.handled.Add(1)
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				self.CatchException(recover(), in, "consume pak catch can recover error")
+			}()
+			handler(in)
+		}()
+	}
+}
 
-	//switch input := in.(type) {
-	//
-	//}
+// Stats 各来源的事件计数，按来源排序
+func (self *EventSink) Stats() []SourceStats {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	list := make([]SourceStats, 0, len(self.stats))
+	for s, c := range self.stats {
+		list = append(list, SourceStats{Source: s, Sent: c.sent.Load(), Dropped: c.dropped.Load(), Rejected: c.rejected.Load(),
+			Handled: c.handled.Load(), Panics: c.panics.Load(), Unhandled: c.unhandled.Load()})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Source < list[j].Source
+	})
+	return list
 }
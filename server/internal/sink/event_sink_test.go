@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"github.com/rs/zerolog"
+	"simpletools/internal/defs"
+	"simpletools/lib/logger"
+	"testing"
+)
+
+// newTestSink 日志使用zerolog.Nop，测试不在工作目录下写日志文件
+func newTestSink(size int) *EventSink {
+	return NewEventSink(size, &logger.CustomLogger{Logger: zerolog.Nop()})
+}
+
+func statsOf(s *EventSink, source defs.EEventSinkSource) SourceStats {
+	for _, st := range s.Stats() {
+		if st.Source == source {
+			return st
+		}
+	}
+	return SourceStats{Source: source}
+}
+
+func TestHandlerOrder(t *testing.T) {
+	s := newTestSink(1)
+	var order []int
+	for i := 1; i <= 3; i++ {
+		i := i
+		s.On(defs.EssJob, func(ev Event) { order = append(order, i) })
+	}
+	s.On(defs.EssReload, func(ev Event) { order = append(order, 100) })
+	s.ConsumePak(Event{Source: defs.EssJob})
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 3 {
+		t.Fatalf("handlers run in %v, want [1 2 3]", order)
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	s := newTestSink(1)
+	var after bool
+	s.On(defs.EssJob, func(ev Event) { panic("boom") })
+	s.On(defs.EssJob, func(ev Event) { after = true })
+	s.ConsumePak(Event{Source: defs.EssJob, Payload: 1})
+	if !after {
+		t.Fatal("handler after the panicking one was not run")
+	}
+	st := statsOf(s, defs.EssJob)
+	if st.Panics != 1 || st.Handled != 1 {
+		t.Fatalf("stats %+v, want panics=1 handled=1", st)
+	}
+
+	s.ConsumePak(Event{Source: defs.EssReload})
+	if st = statsOf(s, defs.EssReload); st.Unhandled != 1 {
+		t.Fatalf("stats %+v, want unhandled=1", st)
+	}
+}
+
+func TestTrySendDropped(t *testing.T) {
+	s := newTestSink(2)
+	for i := 0; i < 2; i++ {
+		if !s.TrySend(defs.EssJob, i) {
+			t.Fatalf("TrySend %d failed with free queue", i)
+		}
+	}
+	if s.TrySend(defs.EssJob, 2) {
+		t.Fatal("TrySend succeeded with full queue")
+	}
+	st := statsOf(s, defs.EssJob)
+	if st.Sent != 2 || st.Dropped != 1 {
+		t.Fatalf("stats %+v, want sent=2 dropped=1", st)
+	}
+}
+
+func TestCloseDrain(t *testing.T) {
+	s := newTestSink(4)
+	var got []any
+	s.On(defs.EssJob, func(ev Event) { got = append(got, ev.Payload) })
+	for i := 0; i < 3; i++ {
+		if !s.SendSinkQ(defs.EssJob, i) {
+			t.Fatalf("SendSinkQ %d failed before close", i)
+		}
+	}
+	s.Close()
+	s.Close()
+	if s.SendSinkQ(defs.EssJob, 3) || s.TrySend(defs.EssJob, 4) {
+		t.Fatal("send succeeded after close")
+	}
+	for ev := range s.SinkQ { // 与主循环相同，关闭后仍能取出已在队列中的事件
+		s.ConsumePak(ev)
+	}
+	if len(got) != 3 || got[0] != 0 || got[2] != 2 {
+		t.Fatalf("drained %v, want [0 1 2]", got)
+	}
+	if st := statsOf(s, defs.EssJob); st.Rejected != 2 {
+		t.Fatalf("stats %+v, want rejected=2", st)
+	}
+}
@@ -0,0 +1,70 @@
+package translate
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	batchPrompt = `用户会发送一个JSON字符串数组，把数组中的每个字符串分别翻译，只回复长度和顺序都与原数组相同的JSON字符串数组，不要合并或拆分元素，不要回复任何多余的内容。⟦数字⟧形式的标记是占位符，必须原样保留在译文中对应的位置；字符串中的换行也要保留。
+`
+	batchSourcePrompt = `原文语言为：%s。
+`
+	batchDetectPrompt = `原文语言需要你自行判断。
+`
+	BatchMaxRunes = 2000 // 单次请求的原文总长度
+	BatchMaxItems = 50   // 单次请求的最多条数
+)
+
+// BatchPrompt 批量翻译的系统提示词，source为空时由模型自行判断原文语言
+func BatchPrompt(source, target Language) string {
+	prompt := fmt.Sprintf(translatePrompt, target.Name) + batchPrompt
+	if source.IsZero() {
+		return prompt + batchDetectPrompt
+	}
+	return prompt + fmt.Sprintf(batchSourcePrompt, source.Name)
+}
+
+// EncodeBatch 把一组文本编码为发送给模型的JSON数组
+func EncodeBatch(texts []string) string {
+	s, _ := jsoniter.MarshalToString(texts)
+	return s
+}
+
+// DecodeBatch 从模型输出中解析JSON数组，数量与原文不一致时返回错误
+func DecodeBatch(answer string, n int) ([]string, error) {
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("answer is not a json array")
+	}
+	var list []string
+	if err := jsoniter.UnmarshalFromString(answer[start:end+1], &list); err != nil {
+		return nil, fmt.Errorf("answer is not a json array: %w", err)
+	}
+	if len(list) != n {
+		return nil, fmt.Errorf("answer has %d items, want %d", len(list), n)
+	}
+	return list, nil
+}
+
+// Chunk 按长度和条数把文本分组，返回每组在texts中的下标；单条超长时独占一组
+func Chunk(texts []string, maxRunes, maxItems int) [][]int {
+	var groups [][]int
+	var cur []int
+	size := 0
+	for i, text := range texts {
+		n := utf8.RuneCountInString(text)
+		if len(cur) > 0 && (size+n > maxRunes || len(cur) >= maxItems) {
+			groups = append(groups, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, i)
+		size += n
+	}
+	if len(cur) > 0 {
+		groups = append(groups, cur)
+	}
+	return groups
+}
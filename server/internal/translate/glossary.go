@@ -0,0 +1,248 @@
+package translate
+
+import (
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"regexp"
+	"simpletools/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	glossaryMaxTerms  = 5000 // 单个术语表最多的词条数
+	glossaryMaxPrompt = 200  // 单次翻译最多注入提示词的词条数
+	glossaryPrompt    = `翻译时必须严格使用以下术语表中的译法，格式为 原文 => 译文：
+%s`
+)
+
+var glossaryNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ErrInvalidGlossary Save时术语表校验失败，区别于写文件失败
+var ErrInvalidGlossary = errors.New("invalid glossary")
+
+type Term struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type Glossary struct {
+	Name      string `json:"name"`
+	Source    string `json:"source"` // 适用的原文语言，为空不限制
+	Target    string `json:"target"` // 适用的目标语言，为空不限制
+	Terms     []Term `json:"terms"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type GlossarySummary struct {
+	Name      string `json:"name"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	TermCount int    `json:"term_count"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Validate 检查名称和词条，去掉首尾空白和重复的原文
+func (g *Glossary) Validate() error {
+	if !glossaryNameRe.MatchString(g.Name) {
+		return fmt.Errorf("glossary name must match %s", glossaryNameRe.String())
+	}
+	if len(g.Terms) > glossaryMaxTerms {
+		return fmt.Errorf("glossary terms exceed %d", glossaryMaxTerms)
+	}
+	for _, code := range []*string{&g.Source, &g.Target} {
+		if *code == "" {
+			continue
+		}
+		lang, err := ParseLanguage(*code)
+		if err != nil {
+			return err
+		}
+		*code = lang.Code
+	}
+	seen := make(map[string]struct{}, len(g.Terms))
+	terms := make([]Term, 0, len(g.Terms))
+	for _, t := range g.Terms {
+		t.Source, t.Target = strings.TrimSpace(t.Source), strings.TrimSpace(t.Target)
+		if t.Source == "" || t.Target == "" {
+			return fmt.Errorf("glossary term source and target cannot be empty")
+		}
+		key := strings.ToLower(t.Source)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		terms = append(terms, t)
+	}
+	g.Terms = terms
+	return nil
+}
+
+// Matches 术语表是否适用于该原文和目标语言，术语表写明地区时要求地区一致；
+// 原文语言需要自动识别时无法提前判断，不限制原文语言
+func (g *Glossary) Matches(source, target Language) bool {
+	return (source.IsZero() || matchLanguage(g.Source, source)) && matchLanguage(g.Target, target)
+}
+
+// matchLanguage code为空不限制，只写主语言时匹配该语言的所有地区
+func matchLanguage(code string, lang Language) bool {
+	return code == "" || lang.Code == code || strings.HasPrefix(lang.Code, code+"-")
+}
+
+// Relevant 原文中出现的词条，长词优先，最多glossaryMaxPrompt条
+func (g *Glossary) Relevant(content string) []Term {
+	terms := TermsIn(g.Terms, content)
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len(terms[i].Source) > len(terms[j].Source)
+	})
+	if len(terms) > glossaryMaxPrompt {
+		terms = terms[:glossaryMaxPrompt]
+	}
+	return terms
+}
+
+// TermsIn 原文出现在content中的词条，不区分大小写
+func TermsIn(terms []Term, content string) []Term {
+	lower := strings.ToLower(content)
+	var found []Term
+	for _, t := range terms {
+		if strings.Contains(lower, strings.ToLower(t.Source)) {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+// GlossaryPrompt 追加到系统提示词的术语要求，没有相关词条时返回空
+func GlossaryPrompt(terms []Term) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, t := range terms {
+		sb.WriteString(t.Source)
+		sb.WriteString(" => ")
+		sb.WriteString(t.Target)
+		sb.WriteByte('\n')
+	}
+	return fmt.Sprintf(glossaryPrompt, sb.String())
+}
+
+// CheckTerms 返回译文中没有使用规定译法的词条
+func CheckTerms(terms []Term, result string) []Term {
+	lower := strings.ToLower(result)
+	missed := make([]Term, 0)
+	for _, t := range terms {
+		if !strings.Contains(lower, strings.ToLower(t.Target)) {
+			missed = append(missed, t)
+		}
+	}
+	return missed
+}
+
+// GlossaryStore 术语表存储，每次修改后整体写回文件，path为空时只保存在内存
+type GlossaryStore struct {
+	mu         sync.RWMutex
+	path       string
+	glossaries map[string]*Glossary
+}
+
+func NewGlossaryStore(path string) (*GlossaryStore, error) {
+	s := &GlossaryStore{path: path, glossaries: make(map[string]*Glossary)}
+	if path == "" {
+		return s, nil
+	}
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Glossary
+	if err = jsoniter.Unmarshal(bs, &list); err != nil {
+		return nil, fmt.Errorf("glossary file %s: %w", path, err)
+	}
+	for _, g := range list {
+		s.glossaries[g.Name] = g
+	}
+	return s, nil
+}
+
+func (s *GlossaryStore) Get(name string) *Glossary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.glossaries[name]
+}
+
+func (s *GlossaryStore) List() []GlossarySummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]GlossarySummary, 0, len(s.glossaries))
+	for _, g := range s.glossaries {
+		list = append(list, GlossarySummary{Name: g.Name, Source: g.Source, Target: g.Target, TermCount: len(g.Terms), UpdatedAt: g.UpdatedAt})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Save 新建或整体替换术语表，保存的对象之后不再修改，读取方可以直接使用
+func (s *GlossaryStore) Save(g *Glossary) error {
+	if err := g.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGlossary, err)
+	}
+	g.UpdatedAt = time.Now().Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.glossaries[g.Name]
+	s.glossaries[g.Name] = g
+	if err := s.flush(); err != nil {
+		if old != nil {
+			s.glossaries[g.Name] = old
+		} else {
+			delete(s.glossaries, g.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *GlossaryStore) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.glossaries[name]
+	if !ok {
+		return false, nil
+	}
+	delete(s.glossaries, name)
+	if err := s.flush(); err != nil {
+		s.glossaries[name] = old
+		return false, err
+	}
+	return true, nil
+}
+
+// flush 先写临时文件再改名，调用方持有写锁
+func (s *GlossaryStore) flush() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]*Glossary, 0, len(s.glossaries))
+	for _, g := range s.glossaries {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	bs, err := jsoniter.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(s.path, bs, 0644)
+}
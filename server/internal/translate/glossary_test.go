@@ -0,0 +1,177 @@
+package translate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustLanguage(t *testing.T, code string) Language {
+	t.Helper()
+	if code == "" {
+		return Language{}
+	}
+	lang, err := ParseLanguage(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lang
+}
+
+func TestGlossaryValidate(t *testing.T) {
+	g := &Glossary{Name: "game_1", Source: "zh-CN", Target: "en-us", Terms: []Term{
+		{Source: " 血量 ", Target: " HP "},
+		{Source: "Boss", Target: "首领"},
+		{Source: "boss", Target: "头目"}, // 原文不区分大小写重复，保留第一条
+	}}
+	if err := g.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Source != "zh-Hans" || g.Target != "en-US" {
+		t.Fatalf("languages %q %q not normalized", g.Source, g.Target)
+	}
+	if len(g.Terms) != 2 || g.Terms[0] != (Term{"血量", "HP"}) || g.Terms[1].Target != "首领" {
+		t.Fatalf("terms %v", g.Terms)
+	}
+
+	cases := []struct {
+		name string
+		g    Glossary
+	}{
+		{"bad name", Glossary{Name: "a b"}},
+		{"empty name", Glossary{}},
+		{"bad source", Glossary{Name: "g", Source: "xx-invalid-code"}},
+		{"unsupported target", Glossary{Name: "g", Target: "sw"}},
+		{"empty term", Glossary{Name: "g", Terms: []Term{{Source: "a", Target: " "}}}},
+		{"too many terms", Glossary{Name: "g", Terms: make([]Term, glossaryMaxTerms+1)}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.g.Validate(); err == nil {
+				t.Fatal("validate succeeded")
+			}
+		})
+	}
+}
+
+func TestGlossaryMatches(t *testing.T) {
+	cases := []struct {
+		gSource, gTarget string
+		source, target   string
+		ok               bool
+	}{
+		{"", "", "", "ja", true},
+		{"", "en", "", "en-US", true},
+		{"", "en-US", "", "en", false},
+		{"", "en-US", "", "en-GB", false},
+		{"", "en", "", "ja", false},
+		{"zh-Hans", "en", "zh-CN", "en", true},
+		{"zh-Hans", "en", "zh-TW", "en", false},
+		{"zh-Hans", "en", "ja", "en", false},
+		{"zh-Hans", "en", "", "en", true}, // 原文语言自动识别
+		{"pt", "", "pt-BR", "en", true},
+	}
+	for _, tc := range cases {
+		g := &Glossary{Source: tc.gSource, Target: tc.gTarget}
+		if got := g.Matches(mustLanguage(t, tc.source), mustLanguage(t, tc.target)); got != tc.ok {
+			t.Fatalf("glossary %q=>%q matches %q=>%q: %v, want %v", tc.gSource, tc.gTarget, tc.source, tc.target, got, tc.ok)
+		}
+	}
+}
+
+func TestTermsIn(t *testing.T) {
+	terms := []Term{{"HP", "血量"}, {"Boss", "首领"}, {"Boss Fight", "首领战"}, {"MP", "法力"}}
+	found := TermsIn(terms, "the boss fight costs hp")
+	if len(found) != 3 || found[0].Source != "HP" || found[1].Source != "Boss" || found[2].Source != "Boss Fight" {
+		t.Fatalf("found %v", found)
+	}
+
+	g := &Glossary{Terms: terms}
+	relevant := g.Relevant("the boss fight costs hp")
+	if len(relevant) != 3 || relevant[0].Source != "Boss Fight" || relevant[1].Source != "Boss" || relevant[2].Source != "HP" {
+		t.Fatalf("relevant %v, want longest first", relevant)
+	}
+
+	many := make([]Term, glossaryMaxPrompt+10)
+	for i := range many {
+		many[i] = Term{Source: fmt.Sprintf("t%03d", i), Target: "x"}
+	}
+	g = &Glossary{Terms: many}
+	if got := g.Relevant("t0 t1"); len(got) != 0 {
+		t.Fatalf("relevant %v for unrelated content", got)
+	}
+	content := ""
+	for _, term := range many {
+		content += term.Source + " "
+	}
+	if got := g.Relevant(content); len(got) != glossaryMaxPrompt {
+		t.Fatalf("relevant %d terms, want %d", len(got), glossaryMaxPrompt)
+	}
+}
+
+func TestCheckTerms(t *testing.T) {
+	terms := []Term{{"HP", "Health"}, {"Boss", "首领"}, {"MP", "Mana"}}
+	cases := []struct {
+		result string
+		missed []string
+	}{
+		{"首领的health和mana都很高", nil},
+		{"首领的血量很高", []string{"HP", "MP"}},
+		{"", []string{"HP", "Boss", "MP"}},
+	}
+	for _, tc := range cases {
+		missed := CheckTerms(terms, tc.result)
+		if missed == nil || len(missed) != len(tc.missed) {
+			t.Fatalf("result %q missed %v, want %v", tc.result, missed, tc.missed)
+		}
+		for i, term := range missed {
+			if term.Source != tc.missed[i] {
+				t.Fatalf("result %q missed %v, want %v", tc.result, missed, tc.missed)
+			}
+		}
+	}
+	if GlossaryPrompt(nil) != "" || GlossaryPrompt(terms) == "" {
+		t.Fatal("glossary prompt")
+	}
+}
+
+func TestGlossaryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossary.json")
+	s, err := NewGlossaryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Save(&Glossary{Name: "bad name"}); !errors.Is(err, ErrInvalidGlossary) {
+		t.Fatalf("save invalid err:%v, want ErrInvalidGlossary", err)
+	}
+	if err = s.Save(&Glossary{Name: "game", Target: "en", Terms: []Term{{"血量", "HP"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewGlossaryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := loaded.Get("game"); g == nil || g.Target != "en" || len(g.Terms) != 1 || g.UpdatedAt == 0 {
+		t.Fatalf("loaded %+v", g)
+	}
+	if list := loaded.List(); len(list) != 1 || list[0].TermCount != 1 {
+		t.Fatalf("list %+v", list)
+	}
+
+	// 写文件失败时回滚：目标路径换成非空目录，改名失败
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(path, "block"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := loaded.Delete("game"); ok || err == nil || loaded.Get("game") == nil {
+		t.Fatalf("delete with unwritable file ok:%v err:%v", ok, err)
+	}
+	if err = loaded.Save(&Glossary{Name: "other"}); err == nil || errors.Is(err, ErrInvalidGlossary) || loaded.Get("other") != nil {
+		t.Fatalf("save with unwritable file err:%v", err)
+	}
+}
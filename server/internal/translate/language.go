@@ -0,0 +1,112 @@
+package translate
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"simpletools/internal/defs"
+	"strings"
+	"unicode"
+)
+
+// Language 规范化后的BCP-47语言，Code用于接口返回，Name用于提示词
+type Language struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func (l Language) IsZero() bool {
+	return l.Code == ""
+}
+
+// supported 支持的语言，key为规范化后的主语言代码，中文按简繁区分
+var supported = map[string]string{
+	"zh-Hans": "简体中文",
+	"zh-Hant": "繁体中文",
+	"en":      "英语",
+	"ja":      "日语",
+	"ko":      "韩语",
+	"fr":      "法语",
+	"de":      "德语",
+	"es":      "西班牙语",
+	"pt":      "葡萄牙语",
+	"it":      "意大利语",
+	"ru":      "俄语",
+	"uk":      "乌克兰语",
+	"pl":      "波兰语",
+	"nl":      "荷兰语",
+	"tr":      "土耳其语",
+	"ar":      "阿拉伯语",
+	"hi":      "印地语",
+	"th":      "泰语",
+	"vi":      "越南语",
+	"id":      "印度尼西亚语",
+	"ms":      "马来语",
+}
+
+// ParseLanguage 解析BCP-47语言代码，如 zh-CN、zh-TW、en-US、pt-BR，不支持的语言返回错误
+// 中文统一规范为zh-Hans/zh-Hant，其他语言保留明确写出的地区，如pt-BR
+func ParseLanguage(code string) (Language, error) {
+	tag, err := language.Parse(strings.TrimSpace(code))
+	if err != nil {
+		return Language{}, fmt.Errorf("invalid language code:%s", code)
+	}
+	base, _ := tag.Base()
+	key := base.String()
+	if key == "zh" {
+		script, _ := tag.Script() // 未写明时根据地区推断，zh-TW/zh-HK为繁体
+		key = "zh-" + script.String()
+	}
+	name, ok := supported[key]
+	if !ok {
+		return Language{}, fmt.Errorf("unsupported language:%s", code)
+	}
+	if region, conf := tag.Region(); conf == language.Exact && base.String() != "zh" {
+		return Language{Code: key + "-" + region.String(), Name: fmt.Sprintf("%s(%s)", name, region.String())}, nil
+	}
+	return Language{Code: key, Name: name}, nil
+}
+
+// FromLanguageType 兼容旧接口的language参数
+func FromLanguageType(t defs.LanguageType) (Language, error) {
+	switch t {
+	case defs.LanguageTypeChinese:
+		return ParseLanguage("zh-Hans")
+	case defs.LanguageTypeEnglish:
+		return ParseLanguage("en")
+	}
+	return Language{}, fmt.Errorf("unsupported language type:%d", t)
+}
+
+// GuessLanguage 根据文字系统粗略判断语言，模型没有给出原文语言时兜底使用；
+// 拉丁字母等多种语言共用的文字无法区分，没有其他文字时返回空语言
+func GuessLanguage(text string) Language {
+	counts := make(map[string]int)
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			counts["ja"] += 2 // 日文通常夹杂汉字，假名权重更高
+		case unicode.Is(unicode.Han, r):
+			counts["zh-Hans"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		}
+	}
+	best, bestCount := "", 0
+	for code, count := range counts {
+		if count > bestCount || (count == bestCount && code < best) {
+			best, bestCount = code, count
+		}
+	}
+	if best == "" {
+		return Language{}
+	}
+	return Language{Code: best, Name: supported[best]}
+}
@@ -0,0 +1,61 @@
+package translate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// placeholderRe 翻译时需要原样保留的内容：行内代码、链接地址、HTML标签和实体、URL、{name}/{{name}}/${name}、printf格式符
+var placeholderRe = regexp.MustCompile("`[^`\\n]+`" +
+	`|\]\([^)\s]*(?:\s+"[^"]*")?\)` +
+	`|</?[A-Za-z][^<>]*>|&[A-Za-z]+;|&#\d+;` +
+	`|https?://[^\s<>()"'` + "`" + `]+` +
+	`|\{\{[^{}]*\}\}|\$?\{[^{}\s]*\}` +
+	`|%\([A-Za-z_]\w*\)[sdif]|%(?:\d+\$)?[-+0#]*\d*(?:\.\d+)?[sdfiuxXoeEgGcqvtTpb@%]`)
+
+var tokenRe = regexp.MustCompile(`⟦\s*(\d+)\s*⟧`)
+
+const tokenFormat = "⟦%d⟧"
+
+// Protect 把占位符替换为⟦n⟧标记，返回替换后的文本和按序号排列的原始内容
+func Protect(text string) (string, []string) {
+	var holders []string
+	masked := placeholderRe.ReplaceAllStringFunc(text, func(m string) string {
+		holders = append(holders, m)
+		return fmt.Sprintf(tokenFormat, len(holders)-1)
+	})
+	return masked, holders
+}
+
+// Restore 把译文中的⟦n⟧标记还原，每个标记必须恰好出现一次
+func Restore(text string, holders []string) (string, error) {
+	counts := make([]int, len(holders))
+	var err error
+	restored := tokenRe.ReplaceAllStringFunc(text, func(m string) string {
+		i, _ := strconv.Atoi(tokenRe.FindStringSubmatch(m)[1])
+		if i >= len(holders) {
+			err = fmt.Errorf("unknown placeholder %s", m)
+			return m
+		}
+		counts[i]++
+		return holders[i]
+	})
+	if err != nil {
+		return "", err
+	}
+	for i, n := range counts {
+		if n != 1 {
+			return "", fmt.Errorf("placeholder %s appears %d times", holders[i], n)
+		}
+	}
+	return restored, nil
+}
+
+// Translatable 去掉占位符后仍包含文字才需要翻译，纯数字、符号、URL等原样保留
+func Translatable(text string) bool {
+	masked := placeholderRe.ReplaceAllString(text, "")
+	return strings.IndexFunc(masked, unicode.IsLetter) >= 0
+}
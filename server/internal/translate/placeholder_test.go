@@ -0,0 +1,74 @@
+package translate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	cases := []struct {
+		text    string
+		masked  string
+		holders []string
+	}{
+		{"Run `go test` now", "Run ⟦0⟧ now", []string{"`go test`"}},
+		{"See [docs](https://a.com/x \"T\")", "See [docs⟦0⟧", []string{"](https://a.com/x \"T\")"}},
+		{"Open https://example.com/a?b=1 today", "Open ⟦0⟧ today", []string{"https://example.com/a?b=1"}},
+		{"<b>Hi</b> &amp; &#169;", "⟦0⟧Hi⟦1⟧ ⟦2⟧ ⟦3⟧", []string{"<b>", "</b>", "&amp;", "&#169;"}},
+		{"Hello {name}, {{count}} ${total}", "Hello ⟦0⟧, ⟦1⟧ ⟦2⟧", []string{"{name}", "{{count}}", "${total}"}},
+		{"%s has %d items, %(user)s %1$s %.2f 100%%", "⟦0⟧ has ⟦1⟧ items, ⟦2⟧ ⟦3⟧ ⟦4⟧ 100⟦5⟧", []string{"%s", "%d", "%(user)s", "%1$s", "%.2f", "%%"}},
+		{"plain text", "plain text", nil},
+	}
+	for _, tc := range cases {
+		masked, holders := Protect(tc.text)
+		if masked != tc.masked || !reflect.DeepEqual(holders, tc.holders) {
+			t.Fatalf("Protect(%q) = %q %q, want %q %q", tc.text, masked, holders, tc.masked, tc.holders)
+		}
+		restored, err := Restore(masked, holders)
+		if err != nil || restored != tc.text {
+			t.Fatalf("Restore(%q) = %q, %v, want %q", masked, restored, err, tc.text)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	holders := []string{"{name}", "%d"}
+	cases := []struct {
+		name string
+		text string
+		want string
+		ok   bool
+	}{
+		{"reordered", "⟦1⟧个 ⟦0⟧", "%d个 {name}", true},
+		{"spaces inside marker", "⟦ 0 ⟧ ⟦1 ⟧", "{name} %d", true},
+		{"missing", "⟦0⟧", "", false},
+		{"duplicated", "⟦0⟧ ⟦0⟧ ⟦1⟧", "", false},
+		{"unknown", "⟦0⟧ ⟦1⟧ ⟦2⟧", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Restore(tc.text, holders)
+			if (err == nil) != tc.ok || got != tc.want {
+				t.Fatalf("got %q err:%v, want %q ok:%v", got, err, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestTranslatable(t *testing.T) {
+	cases := map[string]bool{
+		"Hello":               true,
+		"你好":                  true,
+		"123 456":             false,
+		"{name}":              false,
+		"https://example.com": false,
+		"`code` %s":           false,
+		"`code` and text":     true,
+		"":                    false,
+	}
+	for text, want := range cases {
+		if got := Translatable(text); got != want {
+			t.Fatalf("Translatable(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
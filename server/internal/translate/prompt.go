@@ -0,0 +1,102 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	translatePrompt = `
+你是一个翻译专家。用户可以向助手发送需要翻译的内容，在用户输入的文本中可能包含多种语言、网络用语、缩写或混合表达登，助手会回答相应的翻译结果，你可以调整语气和风格，并考虑到某些词语的文化内涵和地区差异。同时作为翻译家，需将原文翻译成具有信达雅标准的译文。"信" 即忠实于原文的内容与意图；"达" 意味着译文应通顺易懂，表达清晰；"雅" 则追求译文的文化审美和语言的优美。目标是创作出既忠于原作精神，又符合目标语言文化和读者审美的翻译。本次需要翻译的目标语言为：%s。
+`
+	sourcePrompt = `原文语言为：%s。只回复译文，不要回复任何多余的内容。
+`
+	detectPrompt = `原文语言需要你自行判断。回复的第一行必须是 <source>原文语言的BCP-47代码</source>，例如 <source>ja</source>，从第二行开始只回复译文，不要回复任何多余的内容。
+`
+	sourceTagOpen  = "<source>"
+	sourceTagClose = "</source>"
+	sourceTagLimit = 64 // 超过该长度仍未出现完整标记，视为模型没有按要求输出
+)
+
+// Prompt 翻译系统提示词，source为空时要求模型在译文前输出原文语言
+func Prompt(source, target Language) string {
+	prompt := fmt.Sprintf(translatePrompt, target.Name)
+	if source.IsZero() {
+		return prompt + detectPrompt
+	}
+	return prompt + fmt.Sprintf(sourcePrompt, source.Name)
+}
+
+// ExtractSource 拆分模型输出中的原文语言标记和译文，没有标记时ok为false，text原样返回；
+// 标记总是去掉：sourceTagLimit内闭合时去掉到闭合标记为止，否则去掉标记所在的整行。
+// code不一定是合法的语言代码，无法解析时由调用方根据原文判断
+func ExtractSource(text string) (code, rest string, ok bool) {
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	if !strings.HasPrefix(trimmed, sourceTagOpen) {
+		return "", text, false
+	}
+	body := trimmed[len(sourceTagOpen):]
+	if end := strings.Index(body, sourceTagClose); end >= 0 && end <= sourceTagLimit {
+		code, rest = body[:end], body[end+len(sourceTagClose):]
+	} else if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		code, rest = body[:nl], body[nl:]
+	} else { // 整个输出只有一行，只能去掉开头的标记
+		rest = body
+	}
+	if code = strings.TrimSpace(code); len(code) > sourceTagLimit {
+		code = ""
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	rest = strings.TrimPrefix(strings.TrimPrefix(rest, "\r"), "\n") // 去掉标记所在行的换行
+	return code, rest, true
+}
+
+// SourceFilter 流式输出时去掉译文前的原文语言标记，标记处理完之前先缓存增量，去掉的内容与ExtractSource一致
+type SourceFilter struct {
+	buf    strings.Builder
+	passed bool // 标记已处理，之后的增量原样输出
+}
+
+func (f *SourceFilter) Filter(delta string) string {
+	if f.passed {
+		return delta
+	}
+	f.buf.WriteString(delta)
+	text := f.buf.String()
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	if len(trimmed) < len(sourceTagOpen) && strings.HasPrefix(sourceTagOpen, trimmed) {
+		return "" // 标记开头还不完整
+	}
+	if !strings.HasPrefix(trimmed, sourceTagOpen) {
+		return f.pass(text)
+	}
+	body := trimmed[len(sourceTagOpen):]
+	end := strings.Index(body, sourceTagClose)
+	switch {
+	case end >= 0 && end <= sourceTagLimit:
+		if strings.TrimLeft(body[end+len(sourceTagClose):], " \t\r") == "" {
+			return "" // 等待标记后的换行
+		}
+	case len(body) < sourceTagLimit+len(sourceTagClose):
+		return "" // 闭合标记可能还没有输出
+	case !strings.Contains(body, "\n"):
+		return "" // 标记没有按要求闭合，等到换行后去掉整行
+	}
+	_, rest, _ := ExtractSource(text)
+	return f.pass(rest)
+}
+
+func (f *SourceFilter) pass(text string) string {
+	f.passed = true
+	f.buf.Reset()
+	return text
+}
+
+// Flush 流结束时输出仍在缓存中的内容
+func (f *SourceFilter) Flush() string {
+	if f.passed {
+		return ""
+	}
+	_, rest, _ := ExtractSource(f.buf.String())
+	return f.pass(rest)
+}
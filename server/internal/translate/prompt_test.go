@@ -0,0 +1,132 @@
+package translate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLanguage(t *testing.T) {
+	cases := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"zh", "zh-Hans", true},
+		{"zh-CN", "zh-Hans", true},
+		{"zh-TW", "zh-Hant", true},
+		{"zh-HK", "zh-Hant", true},
+		{"zh-Hant-CN", "zh-Hant", true},
+		{"en", "en", true},
+		{" en-US ", "en-US", true},
+		{"pt-BR", "pt-BR", true},
+		{"ja", "ja", true},
+		{"sw", "", false},
+		{"not a code", "", false},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		lang, err := ParseLanguage(tc.code)
+		if (err == nil) != tc.ok || lang.Code != tc.want {
+			t.Fatalf("ParseLanguage(%q) = %+v, %v, want %q", tc.code, lang, err, tc.want)
+		}
+		if tc.ok && lang.Name == "" {
+			t.Fatalf("ParseLanguage(%q) has no name", tc.code)
+		}
+	}
+}
+
+func TestGuessLanguage(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"今天天气很好", "zh-Hans"},
+		{"今日はいい天気ですね", "ja"},
+		{"오늘 날씨가 좋네요", "ko"},
+		{"Сегодня хорошая погода", "ru"},
+		{"Hello 世界", "zh-Hans"},
+		{"Hello world", ""}, // 拉丁字母无法区分语言
+		{"Bonjour le monde", ""},
+		{"12345 !?", ""},
+	}
+	for _, tc := range cases {
+		if got := GuessLanguage(tc.text); got.Code != tc.want {
+			t.Fatalf("GuessLanguage(%q) = %q, want %q", tc.text, got.Code, tc.want)
+		}
+	}
+}
+
+func TestExtractSource(t *testing.T) {
+	long := strings.Repeat("x", sourceTagLimit+1)
+	cases := []struct {
+		name string
+		text string
+		code string
+		rest string
+		ok   bool
+	}{
+		{"tag line", "<source>ja</source>\n你好", "ja", "你好", true},
+		{"crlf and spaces", "  <source> en </source> \r\n你好\n世界", "en", "你好\n世界", true},
+		{"same line", "<source>ja</source>你好", "ja", "你好", true},
+		{"invalid code still stripped", "<source>japanese please</source>\n你好", "japanese please", "你好", true},
+		{"unclosed", "<source>ja\n你好", "ja", "你好", true},
+		{"longer than limit", "<source>" + long + "</source>\n你好", "", "你好", true},
+		{"unclosed single line", "<source>你好", "", "你好", true},
+		{"no tag", "你好<source>ja</source>", "", "你好<source>ja</source>", false},
+		{"empty", "", "", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, rest, ok := ExtractSource(tc.text)
+			if code != tc.code || rest != tc.rest || ok != tc.ok {
+				t.Fatalf("got (%q, %q, %v), want (%q, %q, %v)", code, rest, ok, tc.code, tc.rest, tc.ok)
+			}
+		})
+	}
+}
+
+// TestSourceFilter 任意切分增量时流式输出与ExtractSource的结果一致
+func TestSourceFilter(t *testing.T) {
+	long := strings.Repeat("x", sourceTagLimit+1)
+	texts := []string{
+		"<source>ja</source>\n你好，世界",
+		"\n<source>en</source>\r\nhello\nworld",
+		"<source>ja</source>你好",
+		"<source>ja\n你好",
+		"<source>" + long + "</source>\n你好",
+		"<source>" + long + "\n你好",
+		"<source>你好",
+		"<sou",
+		"<b>你好</b>",
+		"你好",
+		"",
+	}
+	for _, text := range texts {
+		_, want, _ := ExtractSource(text)
+		for _, size := range []int{1, 2, 3, 7, len(text) + 1} {
+			f := &SourceFilter{}
+			var sb strings.Builder
+			for i := 0; i < len(text); i += size {
+				sb.WriteString(f.Filter(text[i:min(i+size, len(text))]))
+			}
+			sb.WriteString(f.Flush())
+			if sb.String() != want {
+				t.Fatalf("text %q split by %d: got %q, want %q", text, size, sb.String(), want)
+			}
+		}
+	}
+}
+
+func TestSourceFilterPassThrough(t *testing.T) {
+	// 没有标记时第一段增量就原样输出，不等待
+	f := &SourceFilter{}
+	if got := f.Filter("你好"); got != "你好" {
+		t.Fatalf("first delta %q", got)
+	}
+	if got := f.Filter("<source>"); got != "<source>" {
+		t.Fatalf("delta after pass %q", got)
+	}
+	if got := f.Flush(); got != "" {
+		t.Fatalf("flush %q", got)
+	}
+}
@@ -0,0 +1,213 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"regexp"
+	"simpletools/internal/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	passwordMinLen = 8
+	passwordMaxLen = 72 // bcrypt只使用前72字节
+)
+
+var (
+	usernameRe = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+	// dummyHash 用户不存在时同样执行一次比较，避免通过响应时间判断用户名是否存在
+	dummyHash, _ = bcrypt.GenerateFromPassword([]byte("simpletools-dummy-password"), bcrypt.DefaultCost)
+)
+
+// User 登录用户，只保存bcrypt哈希，不保存明文密码
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Disabled     bool   `json:"disabled"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+// Summary 去掉密码哈希后的用户信息，用于接口返回
+type Summary struct {
+	Username  string `json:"username"`
+	Disabled  bool   `json:"disabled"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (u *User) Summary() Summary {
+	return Summary{Username: u.Username, Disabled: u.Disabled, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt}
+}
+
+// Store 用户存储，默认实现为FileStore，可以替换为数据库等其他实现
+type Store interface {
+	// Get 用户不存在时返回nil, nil
+	Get(username string) (*User, error)
+	List() ([]*User, error)
+	Save(user *User) error
+	Delete(username string) (bool, error)
+}
+
+func ValidateUsername(username string) error {
+	if !usernameRe.MatchString(username) {
+		return fmt.Errorf("username must match %s", usernameRe.String())
+	}
+	return nil
+}
+
+// HashPassword 生成bcrypt哈希
+func HashPassword(password string) (string, error) {
+	if len(password) < passwordMinLen || len(password) > passwordMaxLen {
+		return "", fmt.Errorf("password length must be in [%d, %d]", passwordMinLen, passwordMaxLen)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Authenticate 校验用户名和密码，用户不存在、已禁用或密码错误都返回nil，调用方不区分具体原因
+func Authenticate(store Store, username, password string) (*User, error) {
+	user, err := store.Get(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, nil
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// FileStore 用户保存在一个json文件中，每次修改后整体写回，path为空时只保存在内存
+type FileStore struct {
+	mu    sync.RWMutex
+	path  string
+	users map[string]*User
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, users: make(map[string]*User)}
+	if path == "" {
+		return s, nil
+	}
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*User
+	if err = jsoniter.Unmarshal(bs, &list); err != nil {
+		return nil, fmt.Errorf("users file %s: %w", path, err)
+	}
+	for _, u := range list {
+		s.users[u.Username] = u
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if u, ok := s.users[username]; ok {
+		cp := *u
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (s *FileStore) List() ([]*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]*User, 0, len(s.users))
+	for _, u := range s.users {
+		cp := *u
+		list = append(list, &cp)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Username < list[j].Username
+	})
+	return list, nil
+}
+
+// Save 新建或替换用户，保留原有的创建时间，user的时间字段会被更新，存储中保存的是副本
+func (s *FileStore) Save(user *User) error {
+	if err := ValidateUsername(user.Username); err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		return fmt.Errorf("user %s password is empty", user.Username)
+	}
+	now := time.Now().Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.users[user.Username]
+	user.CreatedAt, user.UpdatedAt = now, now
+	if old != nil {
+		user.CreatedAt = old.CreatedAt
+	}
+	cp := *user
+	s.users[cp.Username] = &cp
+	if err := s.flush(); err != nil {
+		if old != nil {
+			s.users[cp.Username] = old
+		} else {
+			delete(s.users, cp.Username)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(username string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.users[username]
+	if !ok {
+		return false, nil
+	}
+	delete(s.users, username)
+	if err := s.flush(); err != nil {
+		s.users[username] = old
+		return false, err
+	}
+	return true, nil
+}
+
+// flush 先写临时文件再改名，文件包含密码哈希，只允许当前用户读写；调用方持有写锁
+func (s *FileStore) flush() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]*User, 0, len(s.users))
+	for _, u := range s.users {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Username < list[j].Username
+	})
+	bs, err := jsoniter.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(s.path, bs, 0600)
+}
@@ -0,0 +1,157 @@
+package users
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newUser(t *testing.T, s Store, username, password string, disabled bool) {
+	t.Helper()
+	hash, err := HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Save(&User{Username: username, PasswordHash: hash, Disabled: disabled}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, _ := NewFileStore("")
+	newUser(t, s, "alice", "alice-password", false)
+	newUser(t, s, "bob", "bob-password", true)
+
+	cases := []struct {
+		name     string
+		username string
+		password string
+		ok       bool
+	}{
+		{"ok", "alice", "alice-password", true},
+		{"wrong password", "alice", "bob-password", false},
+		{"empty password", "alice", "", false},
+		{"password prefix", "alice", "alice-passwor", false},
+		{"unknown user", "carol", "alice-password", false},
+		{"username case sensitive", "Alice", "alice-password", false},
+		{"disabled user", "bob", "bob-password", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			user, err := Authenticate(s, tc.username, tc.password)
+			if err != nil {
+				t.Fatal(err) // 登录失败不返回错误，调用方不区分具体原因
+			}
+			if (user != nil) != tc.ok {
+				t.Fatalf("user %+v, want ok:%v", user, tc.ok)
+			}
+			if user != nil && user.Username != tc.username {
+				t.Fatalf("authenticated as %s", user.Username)
+			}
+		})
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	for _, password := range []string{"short", strings.Repeat("x", passwordMaxLen+1)} {
+		if _, err := HashPassword(password); err == nil {
+			t.Fatalf("password of length %d accepted", len(password))
+		}
+	}
+	hash, err := HashPassword("secret-password")
+	if err != nil || hash == "" || strings.Contains(hash, "secret-password") {
+		t.Fatalf("hash %q err %v", hash, err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Save(&User{Username: "bad name", PasswordHash: "x"}); err == nil {
+		t.Fatal("invalid username saved")
+	}
+	if err = s.Save(&User{Username: "alice"}); err == nil {
+		t.Fatal("empty password hash saved")
+	}
+	newUser(t, s, "alice", "alice-password", false)
+	created, _ := s.Get("alice")
+
+	// 修改时保留创建时间，返回的是副本
+	created.Disabled = true
+	if u, _ := s.Get("alice"); u.Disabled {
+		t.Fatal("store modified through returned user")
+	}
+	if err = s.Save(&User{Username: "alice", PasswordHash: created.PasswordHash, Disabled: true, CreatedAt: 1}); err != nil {
+		t.Fatal(err)
+	}
+	newUser(t, s, "bob", "bob-password", false)
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("users file mode %v err %v", info, err)
+	}
+	loaded, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := loaded.List()
+	if len(list) != 2 || list[0].Username != "alice" || !list[0].Disabled || list[0].CreatedAt != created.CreatedAt || list[1].Username != "bob" {
+		t.Fatalf("loaded %+v", list)
+	}
+	if ok, err := loaded.Delete("carol"); ok || err != nil {
+		t.Fatalf("delete unknown ok:%v err:%v", ok, err)
+	}
+
+	if err = os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewFileStore(path); err == nil {
+		t.Fatal("corrupted users file loaded")
+	}
+}
+
+// TestFileStoreRollback 写文件失败时内存中的修改需要回滚，不能出现内存和文件不一致
+func TestFileStoreRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newUser(t, s, "alice", "alice-password", false)
+	before, _ := s.Get("alice")
+
+	// 目标路径换成非空目录，改名失败(以root运行时权限位不能阻止写入)
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(path, "block"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, _ := HashPassword("new-password")
+	if err = s.Save(&User{Username: "alice", PasswordHash: hash, Disabled: true}); err == nil {
+		t.Fatal("save succeeded")
+	}
+	if u, _ := s.Get("alice"); *u != *before {
+		t.Fatalf("update not rolled back: %+v", u)
+	}
+	if err = s.Save(&User{Username: "bob", PasswordHash: hash}); err == nil {
+		t.Fatal("save succeeded")
+	}
+	if u, _ := s.Get("bob"); u != nil {
+		t.Fatal("new user not rolled back")
+	}
+	if ok, err := s.Delete("alice"); ok || err == nil {
+		t.Fatalf("delete ok:%v err:%v", ok, err)
+	}
+	if u, _ := s.Get("alice"); u == nil {
+		t.Fatal("delete not rolled back")
+	}
+	if user, err := Authenticate(s, "alice", "alice-password"); user == nil || err != nil {
+		t.Fatalf("old password rejected after failed update: %v", err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/md5"
+	crand "crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/base64"
@@ -32,6 +34,12 @@ func SHA256(bytes []byte) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+func HmacSHA256(bytes, key []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(bytes)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 var stringList = []rune("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func RandomString(count int) string {
@@ -42,6 +50,15 @@ func RandomString(count int) string {
 	return string(b)
 }
 
+// SecureToken 使用crypto/rand生成n字节随机数，返回hex字符串，用于会话id和刷新token等不可猜测的值
+func SecureToken(n int) string {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
 // PKCS7Padding 用于填充
 func PKCS7Padding(src []byte, blockSize int) []byte {
 	padding := blockSize - len(src)%blockSize
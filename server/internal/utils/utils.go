@@ -3,6 +3,7 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	jsoniter "github.com/json-iterator/go"
 	"runtime"
 	"runtime/debug"
 	"strconv"
@@ -66,3 +67,24 @@ func (aj AnyJson) GetNumber(key string) float64 {
 func (aj AnyJson) GetInt64(key string) int64 {
 	return int64(aj.GetNumber(key))
 }
+
+// Decode 把key对应的任意json值解析到out中，用于数组、对象等复杂参数
+func (aj AnyJson) Decode(key string, out any) error {
+	v, ok := aj[key]
+	if !ok {
+		return nil
+	}
+	bs, err := jsoniter.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return jsoniter.Unmarshal(bs, out)
+}
+
+func (aj AnyJson) GetBool(key string) bool {
+	if aj == nil {
+		return false
+	}
+	b, _ := aj[key].(bool)
+	return b
+}
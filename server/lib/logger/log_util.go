@@ -3,6 +3,7 @@ package logger
 import (
 	"github.com/rs/zerolog"
 	"io"
+	"sync/atomic"
 )
 
 type OptionsFunc func(l zerolog.Context) zerolog.Context
@@ -10,6 +11,7 @@ type OptionsFunc func(l zerolog.Context) zerolog.Context
 type CustomLogger struct {
 	Logger zerolog.Logger
 	fl     *FileLogger
+	level  atomic.Int32 // 日志级别，支持运行时修改，Logger本身不再设置级别
 }
 
 type timestampMsHook struct{}
@@ -35,38 +37,56 @@ func NewCustomLogger(config Config, enableConsole bool, opts ...OptionsFunc) *Cu
 	for _, opt := range opts {
 		ctx = opt(ctx)
 	}
-	ctx = hookTimestampMs(ctx)                                   // 最后添加时间戳毫秒
-	logger := ctx.Logger().Level(zerolog.Level(config.LogLevel)) // 设置日志级别，默认为0[debug]
+	ctx = hookTimestampMs(ctx) // 最后添加时间戳毫秒
 	addFlusher(fl)
-	return &CustomLogger{Logger: logger, fl: fl}
+	ml := &CustomLogger{Logger: ctx.Logger(), fl: fl}
+	ml.SetLevel(config.LogLevel) // 设置日志级别，默认为0[debug]
+	return ml
+}
+
+// SetLevel 修改日志级别，线程安全，配置热加载时调用
+func (ml *CustomLogger) SetLevel(level int) {
+	ml.level.Store(int32(level))
+}
+
+func (ml *CustomLogger) GetLevel() int {
+	return int(ml.level.Load())
+}
+
+// event 低于当前级别时返回空事件，空事件的所有方法都不做任何事
+func (ml *CustomLogger) event(level zerolog.Level, fn func() *zerolog.Event) *LogEvent {
+	if level < zerolog.Level(ml.level.Load()) {
+		return &LogEvent{}
+	}
+	return &LogEvent{Event: fn()}
 }
 
 func (ml *CustomLogger) Trace() *LogEvent {
-	return &LogEvent{Event: ml.Logger.Trace()}
+	return ml.event(zerolog.TraceLevel, ml.Logger.Trace)
 }
 
 func (ml *CustomLogger) Debug() *LogEvent {
-	return &LogEvent{Event: ml.Logger.Debug()}
+	return ml.event(zerolog.DebugLevel, ml.Logger.Debug)
 }
 
 func (ml *CustomLogger) Info() *LogEvent {
-	return &LogEvent{Event: ml.Logger.Info()}
+	return ml.event(zerolog.InfoLevel, ml.Logger.Info)
 }
 
 func (ml *CustomLogger) Warn() *LogEvent {
-	return &LogEvent{Event: ml.Logger.Warn()}
+	return ml.event(zerolog.WarnLevel, ml.Logger.Warn)
 }
 
 func (ml *CustomLogger) Error() *LogEvent {
-	return &LogEvent{Event: ml.Logger.Error()}
+	return ml.event(zerolog.ErrorLevel, ml.Logger.Error)
 }
 
 func (ml *CustomLogger) Fatal() *LogEvent {
-	return &LogEvent{Event: ml.Logger.Fatal()}
+	return ml.event(zerolog.FatalLevel, ml.Logger.Fatal)
 }
 
 func (ml *CustomLogger) Panic() *LogEvent {
-	return &LogEvent{Event: ml.Logger.Panic()}
+	return ml.event(zerolog.PanicLevel, ml.Logger.Panic)
 }
 
 func (ml *CustomLogger) Log() *LogEvent {
@@ -29,3 +29,19 @@ export async function httpPost(url, data) {
         throw error; // 抛出错误以便调用方处理
     }
 }
+
+// 服务端错误码对应的提示，与server/internal/defs/err_code.go保持一致
+const errorMessages = {
+    4: '今日AI额度已用完，请明天再试',
+    5: '没有权限',
+    100: 'AI服务异常，请稍后重试',
+    101: 'AI服务额度已用完，请联系管理员',
+    102: 'AI服务繁忙，请稍后重试',
+    103: 'AI服务响应超时，请稍后重试',
+    104: '内容未通过AI服务的安全审核',
+    105: 'AI服务没有返回结果，请调整内容后重试',
+};
+
+export function errorMessage(code) {
+    return errorMessages[code] || '服务暂不可用';
+}